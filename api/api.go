@@ -10,18 +10,21 @@ import (
 	"github.com/irsalhamdi/e-commerce-video/api/background"
 	"github.com/irsalhamdi/e-commerce-video/api/middleware"
 	"github.com/irsalhamdi/e-commerce-video/api/web"
-	"github.com/irsalhamdi/e-commerce-video/config"
 	"github.com/irsalhamdi/e-commerce-video/core/auth"
+	"github.com/irsalhamdi/e-commerce-video/core/bundle"
 	"github.com/irsalhamdi/e-commerce-video/core/cart"
+	"github.com/irsalhamdi/e-commerce-video/core/coupon"
 	"github.com/irsalhamdi/e-commerce-video/core/course"
 	"github.com/irsalhamdi/e-commerce-video/core/order"
+	"github.com/irsalhamdi/e-commerce-video/core/report"
+	"github.com/irsalhamdi/e-commerce-video/core/subscription"
 	"github.com/irsalhamdi/e-commerce-video/core/token"
 	"github.com/irsalhamdi/e-commerce-video/core/user"
 	"github.com/irsalhamdi/e-commerce-video/core/video"
+	"github.com/irsalhamdi/e-commerce-video/core/wishlist"
+	"github.com/irsalhamdi/e-commerce-video/tax"
 	"github.com/jmoiron/sqlx"
-	"github.com/plutov/paypal/v4"
 	"github.com/sirupsen/logrus"
-	stripecl "github.com/stripe/stripe-go/v74/client"
 )
 
 type APIConfig struct {
@@ -32,12 +35,14 @@ type APIConfig struct {
 	Mailer             token.Mailer
 	TokenTimeout       time.Duration
 	Background         *background.Background
-	Paypal             *paypal.Client
-	Stripe             *stripecl.API
-	StripeCfg          config.Stripe
+	Payments           map[string]order.PaymentProvider
+	Subscriptions      subscription.Provider
+	TaxRates           tax.Rates
 	Providers          map[string]auth.Provider
 	LoginRedirectURL   string
 	ActivationRequired bool
+	AdminTOTPRequired  bool
+	UserCacheTTL       time.Duration
 }
 
 type api struct {
@@ -69,20 +74,33 @@ func APIMux(cfg APIConfig) http.Handler {
 		a.Handle(http.MethodOptions, "/{path:.*}", h)
 	}
 
-	authen := auth.Authenticate(cfg.Session)
-	admin := auth.Admin(cfg.Session)
-
-	a.Handle(http.MethodPost, "/auth/signup", auth.HandleSignup(cfg.DB, cfg.Session, cfg.ActivationRequired))
-	a.Handle(http.MethodPost, "/auth/login", auth.HandleLogin(cfg.DB, cfg.Session))
-	a.Handle(http.MethodPost, "/auth/logout", auth.HandleLogout(cfg.Session))
+	users := auth.NewUserCache(cfg.UserCacheTTL)
+	authen := auth.Authenticate(cfg.DB, cfg.Session, users)
+	admin := auth.Admin(cfg.DB, cfg.Session, users, cfg.AdminTOTPRequired)
+
+	gifts := order.ClaimGifts(cfg.DB, cfg.Session)
+	guest := cart.MergeGuest(cfg.DB, cfg.Session, cfg.Log)
+
+	a.Handle(http.MethodPost, "/auth/signup", auth.HandleSignup(cfg.DB, cfg.Session, cfg.ActivationRequired), gifts, guest)
+	a.Handle(http.MethodPost, "/auth/login", auth.HandleLogin(cfg.DB, cfg.Session), gifts, guest)
+	a.Handle(http.MethodPost, "/auth/logout", auth.HandleLogout(cfg.DB, cfg.Session))
+	a.Handle(http.MethodPost, "/auth/totp/verify", auth.HandleVerifyTOTP(cfg.DB, cfg.Session), gifts, guest)
+	a.Handle(http.MethodPost, "/auth/totp", auth.HandleEnrollTOTP(cfg.DB), authen)
+	a.Handle(http.MethodPost, "/auth/totp/confirm", auth.HandleConfirmTOTP(cfg.DB), authen)
+	a.Handle(http.MethodDelete, "/auth/totp", auth.HandleDisableTOTP(cfg.DB), authen)
+	a.Handle(http.MethodGet, "/auth/sessions", auth.HandleListSessions(cfg.DB, cfg.Session), authen)
+	a.Handle(http.MethodDelete, "/auth/sessions", auth.HandleDeleteSessions(cfg.DB, cfg.Session), authen)
+	a.Handle(http.MethodDelete, "/auth/sessions/{id}", auth.HandleDeleteSession(cfg.DB, cfg.Session), authen)
 	a.Handle(http.MethodGet, "/auth/oauth-login/{provider}", auth.HandleOauthLogin(cfg.Session, cfg.Providers))
-	a.Handle(http.MethodGet, "/auth/oauth-callback/{provider}", auth.HandleOauthCallback(cfg.DB, cfg.Session, cfg.Providers, cfg.LoginRedirectURL))
+	a.Handle(http.MethodGet, "/auth/oauth-callback/{provider}", auth.HandleOauthCallback(cfg.DB, cfg.Session, cfg.Providers, cfg.LoginRedirectURL), gifts, guest)
 
 	a.Handle(http.MethodPost, "/tokens", token.HandleToken(cfg.DB, cfg.Mailer, cfg.TokenTimeout, cfg.Background))
-	a.Handle(http.MethodPost, "/tokens/activate", token.HandleActivation(cfg.DB, cfg.Session))
-	a.Handle(http.MethodPost, "/tokens/recover", token.HandleRecovery(cfg.DB))
+	a.Handle(http.MethodPost, "/tokens/activate", token.HandleActivation(cfg.DB, cfg.Session), gifts, guest)
+	a.Handle(http.MethodPost, "/tokens/login", token.HandleLogin(cfg.DB, cfg.Session), gifts, guest)
+	a.Handle(http.MethodPost, "/tokens/recover", token.HandleRecovery(cfg.DB, cfg.Session))
 
 	a.Handle(http.MethodGet, "/users/current", user.HandleShowCurrent(cfg.DB), authen)
+	a.Handle(http.MethodPut, "/users/current/preferences", user.HandleUpdatePreferences(cfg.DB), authen)
 	a.Handle(http.MethodGet, "/users/{id}", user.HandleShow(cfg.DB), authen)
 	a.Handle(http.MethodPost, "/users", user.HandleCreate(cfg.DB), authen)
 
@@ -94,6 +112,12 @@ func APIMux(cfg APIConfig) http.Handler {
 	a.Handle(http.MethodPost, "/courses", course.HandleCreate(cfg.DB), admin)
 	a.Handle(http.MethodPut, "/courses/{id}", course.HandleUpdate(cfg.DB), admin)
 
+	a.Handle(http.MethodGet, "/bundles/{id}", bundle.HandleShow(cfg.DB))
+	a.Handle(http.MethodGet, "/bundles", bundle.HandleList(cfg.DB))
+	a.Handle(http.MethodPost, "/bundles", bundle.HandleCreate(cfg.DB), admin)
+	a.Handle(http.MethodPut, "/bundles/{id}", bundle.HandleUpdate(cfg.DB), admin)
+	a.Handle(http.MethodDelete, "/bundles/{id}", bundle.HandleDelete(cfg.DB), admin)
+
 	a.Handle(http.MethodGet, "/videos/{id}/full", video.HandleShowFull(cfg.DB), authen)
 	a.Handle(http.MethodGet, "/videos/{id}/free", video.HandleShowFree(cfg.DB))
 	a.Handle(http.MethodGet, "/videos/{id}", video.HandleShow(cfg.DB))
@@ -104,13 +128,45 @@ func APIMux(cfg APIConfig) http.Handler {
 
 	a.Handle(http.MethodGet, "/cart", cart.HandleShow(cfg.DB), authen)
 	a.Handle(http.MethodDelete, "/cart", cart.HandleDelete(cfg.DB), authen)
+	a.Handle(http.MethodGet, "/cart/guest", cart.HandleShowGuest(cfg.DB, cfg.Session))
+	a.Handle(http.MethodPut, "/cart/guest/items", cart.HandleCreateGuestItem(cfg.DB, cfg.Session))
+	a.Handle(http.MethodDelete, "/cart/guest/items/{course_id}", cart.HandleDeleteGuestItem(cfg.Session))
 	a.Handle(http.MethodPut, "/cart/items", cart.HandleCreateItem(cfg.DB), authen)
 	a.Handle(http.MethodDelete, "/cart/items/{course_id}", cart.HandleDeleteItem(cfg.DB), authen)
-
-	a.Handle(http.MethodPost, "/orders/paypal", order.HandlePaypalCheckout(cfg.DB, cfg.Paypal), authen)
-	a.Handle(http.MethodPost, "/orders/paypal/{id}/capture", order.HandlePaypalCapture(cfg.DB, cfg.Paypal), authen)
-	a.Handle(http.MethodPost, "/orders/stripe", order.HandleStripeCheckout(cfg.DB, cfg.Stripe, cfg.StripeCfg), authen)
-	a.Handle(http.MethodPost, "/orders/stripe/capture", order.HandleStripeCapture(cfg.DB, cfg.StripeCfg))
+	a.Handle(http.MethodPut, "/cart/bundles", cart.HandleCreateBundle(cfg.DB), authen)
+	a.Handle(http.MethodDelete, "/cart/bundles/{bundle_id}", cart.HandleDeleteBundle(cfg.DB), authen)
+	a.Handle(http.MethodPut, "/cart/coupon", cart.HandleApplyCoupon(cfg.DB), authen)
+	a.Handle(http.MethodDelete, "/cart/coupon", cart.HandleRemoveCoupon(cfg.DB), authen)
+
+	a.Handle(http.MethodGet, "/wishlist/popular", wishlist.HandleListPopular(cfg.DB), admin)
+	a.Handle(http.MethodGet, "/wishlist", wishlist.HandleList(cfg.DB), authen)
+	a.Handle(http.MethodPut, "/wishlist/items", wishlist.HandleCreateItem(cfg.DB), authen)
+	a.Handle(http.MethodDelete, "/wishlist/items/{course_id}", wishlist.HandleDeleteItem(cfg.DB), authen)
+	a.Handle(http.MethodPost, "/wishlist/items/{course_id}/cart", wishlist.HandleMoveToCart(cfg.DB), authen)
+
+	a.Handle(http.MethodGet, "/coupons/{id}", coupon.HandleShow(cfg.DB), admin)
+	a.Handle(http.MethodGet, "/coupons", coupon.HandleList(cfg.DB), admin)
+	a.Handle(http.MethodPost, "/coupons", coupon.HandleCreate(cfg.DB), admin)
+	a.Handle(http.MethodPut, "/coupons/{id}", coupon.HandleUpdate(cfg.DB), admin)
+	a.Handle(http.MethodDelete, "/coupons/{id}", coupon.HandleDelete(cfg.DB), admin)
+
+	a.Handle(http.MethodPost, "/gifts/claim", order.HandleGiftClaim(cfg.DB, cfg.Session))
+
+	a.Handle(http.MethodGet, "/orders", order.HandleList(cfg.DB), authen)
+	a.Handle(http.MethodGet, "/orders/all", order.HandleListAll(cfg.DB), admin)
+	a.Handle(http.MethodGet, "/orders/{id}/receipt", order.HandleReceipt(cfg.DB), authen)
+	a.Handle(http.MethodGet, "/orders/{id}", order.HandleShow(cfg.DB), authen)
+	a.Handle(http.MethodPost, "/orders/free", order.HandleFreeCheckout(cfg.DB, cfg.TaxRates, cfg.Mailer, cfg.Background), authen)
+	a.Handle(http.MethodPost, "/orders/{provider}", order.HandleCheckout(cfg.DB, cfg.Payments, cfg.TaxRates), authen)
+	a.Handle(http.MethodPost, "/orders/{provider}/{id}/capture", order.HandleCapture(cfg.DB, cfg.Payments, cfg.Mailer, cfg.Background), authen)
+	a.Handle(http.MethodPost, "/orders/{provider}/capture", order.HandleWebhook(cfg.DB, cfg.Payments, cfg.Mailer, cfg.Background))
+	a.Handle(http.MethodPost, "/orders/{id}/refund", order.HandleRefund(cfg.DB, cfg.Payments), admin)
+
+	a.Handle(http.MethodGet, "/reports/revenue", report.HandleRevenue(cfg.DB), admin)
+	a.Handle(http.MethodGet, "/reports/conversion", report.HandleConversion(cfg.DB), admin)
+
+	a.Handle(http.MethodGet, "/subscriptions/current", subscription.HandleShowCurrent(cfg.DB), authen)
+	a.Handle(http.MethodPost, "/subscriptions", subscription.HandleCheckout(cfg.DB, cfg.Subscriptions), authen)
 
 	return a.Router
 }
@@ -5,42 +5,71 @@ import (
 	"fmt"
 	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type Background struct {
-	wg  sync.WaitGroup
-	log logrus.FieldLogger
+	wg   sync.WaitGroup
+	log  logrus.FieldLogger
+	quit chan struct{}
+	once sync.Once
 }
 
 func New(log logrus.FieldLogger) *Background {
 	return &Background{
-		log: log,
+		log:  log,
+		quit: make(chan struct{}),
 	}
 }
 
 func (bg *Background) Add(f func() error) {
 	bg.wg.Add(1)
 
+	go func() {
+		defer bg.wg.Done()
+		bg.run(f)
+	}()
+}
+
+func (bg *Background) Every(interval time.Duration, f func() error) {
+	bg.wg.Add(1)
+
 	go func() {
 		defer bg.wg.Done()
 
-		defer func() {
-			if rec := recover(); rec != nil {
-				trace := debug.Stack()
-				err := fmt.Errorf("PANIC [%v] TRACE[%s]", rec, string(trace))
-				bg.log.WithField("message", err).Error("PANIC")
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-bg.quit:
+				return
+			case <-ticker.C:
+				bg.run(f)
 			}
-		}()
+		}
+	}()
+}
 
-		if err := f(); err != nil {
-			bg.log.WithField("message", err).Error("ERROR")
+func (bg *Background) run(f func() error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			trace := debug.Stack()
+			err := fmt.Errorf("PANIC [%v] TRACE[%s]", rec, string(trace))
+			bg.log.WithField("message", err).Error("PANIC")
 		}
 	}()
+
+	if err := f(); err != nil {
+		bg.log.WithField("message", err).Error("ERROR")
+	}
 }
 
 func (bg *Background) Shutdown(ctx context.Context) error {
+	bg.once.Do(func() { close(bg.quit) })
+
 	quit := make(chan struct{})
 	go func() {
 		bg.wg.Wait()
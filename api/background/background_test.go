@@ -108,3 +108,39 @@ func TestBackgroundPanic(t *testing.T) {
 		t.Fatalf("panic should not result in an error: %v", err)
 	}
 }
+
+func TestBackgroundEvery(t *testing.T) {
+	log := logrus.New()
+	bg := New(log)
+
+	unit := time.Millisecond
+	cnt := make(chan int, 100)
+	bg.Every(2*unit, func() error {
+		cnt <- 1
+		return nil
+	})
+
+	bg.Every(2*unit, func() error {
+		panic("now what?")
+	})
+
+	time.Sleep(15 * unit)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*unit)
+	defer cancel()
+
+	if err := bg.Shutdown(ctx); err != nil {
+		t.Fatalf("periodic tasks should stop on shutdown: %v", err)
+	}
+
+	close(cnt)
+
+	var c int
+	for range cnt {
+		c += 1
+	}
+
+	if c < 2 {
+		t.Fatalf("expected the periodic task to run at least twice, got %d", c)
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/irsalhamdi/e-commerce-video/api/web"
@@ -11,7 +12,8 @@ import (
 type fields interface{ Fields() map[string]interface{} }
 
 func Fields(err error) (map[string]interface{}, bool) {
-	if fe, ok := err.(fields); ok {
+	var fe fields
+	if errors.As(err, &fe) {
 		return fe.Fields(), true
 	}
 	return nil, false
@@ -20,7 +22,8 @@ func Fields(err error) (map[string]interface{}, bool) {
 type response interface{ Response() (interface{}, int) }
 
 func Response(err error) (interface{}, int, bool) {
-	if re, ok := err.(response); ok {
+	var re response
+	if errors.As(err, &re) {
 		body, code := re.Response()
 		return body, code, true
 	}
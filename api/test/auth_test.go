@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/irsalhamdi/e-commerce-video/core/auth"
 	"github.com/irsalhamdi/e-commerce-video/core/token"
 	"github.com/irsalhamdi/e-commerce-video/core/user"
 )
@@ -153,6 +155,9 @@ func TestAuth(t *testing.T) {
 	at.loginOK(t)
 	at.loginWrongPass(t)
 	at.loginNotActive(t)
+
+	at.sessionsOK(t)
+	at.userChangesOK(t)
 }
 
 func (at *authTest) signupOK(t *testing.T) {
@@ -248,3 +253,148 @@ func (at *authTest) loginNotActive(t *testing.T) {
 		t.Fatal("inactive users cannot login")
 	}
 }
+
+func (at *authTest) sessionsOK(t *testing.T) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := &http.Client{Transport: at.Client().Transport, Jar: jar}
+
+	if err := Login(at.Server, at.UserEmail, at.UserPass); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, at.URL+"/auth/login", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetBasicAuth(at.UserEmail, at.UserPass)
+	r.Header.Set("User-Agent", "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X)")
+
+	w, err := other.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Body.Close()
+
+	if w.StatusCode != http.StatusNoContent {
+		t.Fatalf("can't login from other device: status code %s", w.Status)
+	}
+
+	sessions := at.listSessionsOK(t, 2)
+	var otherID string
+	for _, s := range sessions {
+		if !s.Current {
+			otherID = s.ID
+			if s.Device != "iPhone" {
+				t.Fatalf("expected other session device to be iPhone, got %s", s.Device)
+			}
+		}
+	}
+	if otherID == "" {
+		t.Fatal("expected exactly one current session")
+	}
+
+	at.deleteSessionOK(t, "/auth/sessions/"+otherID)
+	at.currentUser(t, other, http.StatusUnauthorized)
+	at.currentUser(t, at.Client(), http.StatusOK)
+	at.listSessionsOK(t, 1)
+
+	at.deleteSessionOK(t, "/auth/sessions")
+	at.currentUser(t, at.Client(), http.StatusUnauthorized)
+}
+
+func (at *authTest) listSessionsOK(t *testing.T, n int) []auth.Session {
+	w, err := at.Client().Get(at.URL + "/auth/sessions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't list sessions: status code %s", w.Status)
+	}
+
+	var got []auth.Session
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal sessions: %v", err)
+	}
+
+	if len(got) != n {
+		t.Fatalf("expected %d sessions, got %d", n, len(got))
+	}
+
+	return got
+}
+
+func (at *authTest) deleteSessionOK(t *testing.T, path string) {
+	r, err := http.NewRequest(http.MethodDelete, at.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := at.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusNoContent {
+		t.Fatalf("can't revoke session: status code %s", w.Status)
+	}
+}
+
+func (at *authTest) currentUser(t *testing.T, c *http.Client, status int) {
+	w, err := c.Get(at.URL + "/users/current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status %d showing current user, got %s", status, w.Status)
+	}
+}
+
+func (at *authTest) userChangesOK(t *testing.T) {
+	if err := Login(at.Server, at.AdminEmail, at.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(at.Server)
+
+	at.showCoupons(t, http.StatusOK)
+
+	if _, err := at.DB.Exec("UPDATE users SET role = 'USER' WHERE email = $1", at.AdminEmail); err != nil {
+		t.Fatal(err)
+	}
+	at.showCoupons(t, http.StatusUnauthorized)
+	at.currentUser(t, at.Client(), http.StatusOK)
+
+	if _, err := at.DB.Exec("UPDATE users SET role = 'ADMIN' WHERE email = $1", at.AdminEmail); err != nil {
+		t.Fatal(err)
+	}
+	at.showCoupons(t, http.StatusOK)
+
+	if _, err := at.DB.Exec("UPDATE users SET active = FALSE WHERE email = $1", at.AdminEmail); err != nil {
+		t.Fatal(err)
+	}
+	at.currentUser(t, at.Client(), http.StatusUnauthorized)
+
+	if _, err := at.DB.Exec("UPDATE users SET active = TRUE WHERE email = $1", at.AdminEmail); err != nil {
+		t.Fatal(err)
+	}
+	at.currentUser(t, at.Client(), http.StatusUnauthorized)
+}
+
+func (at *authTest) showCoupons(t *testing.T, status int) {
+	w, err := at.Client().Get(at.URL + "/coupons")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status %d listing coupons, got %s", status, w.Status)
+	}
+}
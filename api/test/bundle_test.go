@@ -0,0 +1,294 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/irsalhamdi/e-commerce-video/core/bundle"
+	"github.com/irsalhamdi/e-commerce-video/core/cart"
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/core/order"
+	"github.com/irsalhamdi/e-commerce-video/money"
+	"github.com/irsalhamdi/e-commerce-video/tax"
+	"github.com/irsalhamdi/e-commerce-video/validate"
+)
+
+type bundleTest struct {
+	*TestEnv
+}
+
+func TestBundle(t *testing.T) {
+	env, err := NewTestEnv(t, "bundle_test")
+	if err != nil {
+		t.Fatalf("initializing test env: %v", err)
+	}
+
+	bt := &bundleTest{env}
+	ct := &courseTest{env}
+	rt := &cartTest{env}
+	ot := &orderTest{env}
+
+	c1 := ct.createCourseOK(t)
+	c2 := ct.createCourseOK(t)
+	c3 := ct.createCourseOK(t)
+
+	bn := bundle.BundleNew{
+		Name:        "Test bundle",
+		Description: "This is a test bundle",
+		Prices:      money.Prices{"USD": 1500, "EUR": 1000},
+		ImageURL:    "/images/bundle.png",
+		CourseIDs:   []string{c1.ID, c2.ID},
+	}
+
+	bt.createBundleUnauth(t, bn)
+	b := bt.createBundleOK(t, bn)
+
+	name := "Updated bundle"
+	b = bt.updateBundleOK(t, b, bundle.BundleUp{Name: &name})
+	bt.listBundlesOK(t, 1)
+
+	bt.addBundle(t, validate.GenerateID(), http.StatusNotFound)
+	bt.addBundle(t, b.ID, http.StatusCreated)
+
+	rt.createItemOK(t, c1.ID)
+	bt.checkoutConflict(t)
+	rt.deleteItemOK(t, c1.ID)
+
+	rt.createItemOK(t, c3.ID)
+	ot.Paypal.expectedCart = []course.Course{c3}
+	ot.Paypal.expectedBundles = []bundle.Bundle{b}
+	ot.Paypal.expectedCurrency = "USD"
+	ot.checkoutPaypal(t, order.CheckoutNew{Currency: "USD", Country: "IT"})
+
+	ct.listCoursesOwnedOK(t, []course.Course{c1, c2, c3})
+
+	orders := ot.listOrdersOK(t, 1, 3)
+	var tot int
+	for _, it := range orders[0].Items {
+		if net, tx := tax.Split(it.Amount, 2200); it.Net != net || it.Tax != tx {
+			t.Fatalf("wrong tax breakdown of item %+v", it)
+		}
+		if it.CourseID != c3.ID {
+			if it.BundleID == nil || *it.BundleID != b.ID {
+				t.Fatalf("expected item of course[%s] to belong to bundle[%s]", it.CourseID, b.ID)
+			}
+			tot += it.Amount
+		}
+	}
+	if tot != b.Prices["USD"] {
+		t.Fatalf("expected bundle items to sum to %d, got %d", b.Prices["USD"], tot)
+	}
+
+	rt.showCartOK(t, cart.Cart{Items: []cart.Item{}, Bundles: []cart.Bundle{}})
+
+	bt.deleteBundleOK(t, b.ID)
+	bt.listBundlesOK(t, 0)
+	ct.listCoursesOwnedOK(t, []course.Course{c1, c2, c3})
+}
+
+func (bt *bundleTest) createBundleOK(t *testing.T, b bundle.BundleNew) bundle.Bundle {
+	if err := Login(bt.Server, bt.AdminEmail, bt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(bt.Server)
+
+	body, err := json.Marshal(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, bt.URL+"/bundles", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := bt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusCreated {
+		t.Fatalf("can't create bundle: status code %s", w.Status)
+	}
+
+	var got bundle.Bundle
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal created bundle: %v", err)
+	}
+
+	if got.Name != b.Name || len(got.Courses) != len(b.CourseIDs) {
+		t.Fatalf("wrong bundle payload: %+v", got)
+	}
+
+	return got
+}
+
+func (bt *bundleTest) createBundleUnauth(t *testing.T, b bundle.BundleNew) {
+	if err := Login(bt.Server, bt.UserEmail, bt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(bt.Server)
+
+	body, err := json.Marshal(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, bt.URL+"/bundles", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := bt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status code %d, got %s", http.StatusUnauthorized, w.Status)
+	}
+}
+
+func (bt *bundleTest) updateBundleOK(t *testing.T, b bundle.Bundle, bup bundle.BundleUp) bundle.Bundle {
+	if err := Login(bt.Server, bt.AdminEmail, bt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(bt.Server)
+
+	body, err := json.Marshal(&bup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPut, bt.URL+"/bundles/"+b.ID, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := bt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't update bundle: status code %s", w.Status)
+	}
+
+	var got bundle.Bundle
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal updated bundle: %v", err)
+	}
+
+	if got.Name != *bup.Name || len(got.Courses) != len(b.Courses) {
+		t.Fatalf("wrong bundle payload: %+v", got)
+	}
+
+	return got
+}
+
+func (bt *bundleTest) listBundlesOK(t *testing.T, n int) {
+	r, err := http.NewRequest(http.MethodGet, bt.URL+"/bundles", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := bt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't list bundles: status code %s", w.Status)
+	}
+
+	var got []bundle.Bundle
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal bundles: %v", err)
+	}
+
+	if len(got) != n {
+		t.Fatalf("expected %d bundles, got %d", n, len(got))
+	}
+}
+
+func (bt *bundleTest) deleteBundleOK(t *testing.T, id string) {
+	if err := Login(bt.Server, bt.AdminEmail, bt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(bt.Server)
+
+	r, err := http.NewRequest(http.MethodDelete, bt.URL+"/bundles/"+id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := bt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusNoContent {
+		t.Fatalf("can't delete bundle: status code %s", w.Status)
+	}
+}
+
+func (bt *bundleTest) addBundle(t *testing.T, id string, status int) {
+	if err := Login(bt.Server, bt.UserEmail, bt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(bt.Server)
+
+	body, err := json.Marshal(cart.BundleNew{BundleID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPut, bt.URL+"/cart/bundles", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := bt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d adding bundle to cart, got %s", status, w.Status)
+	}
+}
+
+func (bt *bundleTest) checkoutConflict(t *testing.T) {
+	if err := Login(bt.Server, bt.UserEmail, bt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(bt.Server)
+
+	body, err := json.Marshal(order.CheckoutNew{Currency: "USD", Country: "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, bt.URL+"/orders/paypal", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := bt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status code %d checking out a course twice, got %s", http.StatusUnprocessableEntity, w.Status)
+	}
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"testing"
@@ -9,6 +10,8 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/irsalhamdi/e-commerce-video/core/cart"
+	"github.com/irsalhamdi/e-commerce-video/core/order"
+	"github.com/irsalhamdi/e-commerce-video/core/user"
 )
 
 type cartTest struct {
@@ -27,16 +30,17 @@ func TestCart(t *testing.T) {
 
 	ct := &cartTest{env}
 
-	ct.showCartOK(t, cart.Cart{Items: []cart.Item{}})
+	ct.showCartOK(t, cart.Cart{Items: []cart.Item{}, Bundles: []cart.Bundle{}})
 
 	item1 := ct.createItemOK(t, course1.ID)
 	item2 := ct.createItemOK(t, course2.ID)
 	ct.showCartOK(t, cart.Cart{
-		Items: []cart.Item{item1, item2},
+		Items:   []cart.Item{item1, item2},
+		Bundles: []cart.Bundle{},
 	})
 
 	ct.deleteCartOK(t)
-	ct.showCartOK(t, cart.Cart{Items: []cart.Item{}})
+	ct.showCartOK(t, cart.Cart{Items: []cart.Item{}, Bundles: []cart.Bundle{}})
 
 	ct.deleteCartOK(t)
 
@@ -44,15 +48,114 @@ func TestCart(t *testing.T) {
 	ct.createItemOK(t, course2.ID)
 	ct.deleteItemOK(t, item1.CourseID)
 	ct.deleteItemOK(t, item2.CourseID)
-	ct.showCartOK(t, cart.Cart{Items: []cart.Item{}})
+	ct.showCartOK(t, cart.Cart{Items: []cart.Item{}, Bundles: []cart.Bundle{}})
+
+	item1 = ct.createItemOK(t, course1.ID)
+	ut.updateCourseOK(t, course1)
+	stale := item1
+	stale.PriceChanged = true
+	ct.showCartOK(t, cart.Cart{Items: []cart.Item{stale}, Bundles: []cart.Bundle{}})
+	ct.checkoutStale(t, course1.ID)
+
+	item1 = ct.createItemOK(t, course1.ID)
+	ct.showCartOK(t, cart.Cart{Items: []cart.Item{item1}, Bundles: []cart.Bundle{}})
+
+	guest := ct.createGuestItemOK(t, course2.ID)
+	ct.createGuestItemOK(t, course2.ID)
+	ct.showGuestCartOK(t, 1)
+	ct.showCartOK(t, cart.Cart{Items: []cart.Item{item1, guest}, Bundles: []cart.Bundle{}})
+	ct.showGuestCartOK(t, 0)
+
+	ct.remindOK(t, 1)
+	ct.remindOK(t, 1)
+
+	ct.Mailer.failing = ct.AdminEmail
+	ct.createItemAs(t, ct.AdminEmail, ct.AdminPass, course1.ID)
+	ct.deleteItemOK(t, item1.CourseID)
+	ct.remindFailed(t, 2)
+	ct.Mailer.failing = ""
+
+	ct.updatePreferencesOK(t, false)
+	ct.createItemOK(t, course1.ID)
+	ct.remindOK(t, 2)
 }
 
-func (ct *cartTest) createItemOK(t *testing.T, courseID string) cart.Item {
+func (ct *cartTest) remindOK(t *testing.T, exp int) {
+	if err := cart.RemindAbandoned(context.Background(), ct.DB, ct.Mailer, 0); err != nil {
+		t.Fatalf("can't remind abandoned carts: %v", err)
+	}
+	ct.remindedOK(t, exp)
+}
+
+// remindFailed checks that a failing reminder doesn't prevent the user's one.
+func (ct *cartTest) remindFailed(t *testing.T, exp int) {
+	if err := cart.RemindAbandoned(context.Background(), ct.DB, ct.Mailer, 0); err == nil {
+		t.Fatal("expected the reminder to the failing mailbox to be reported")
+	}
+	ct.remindedOK(t, exp)
+}
+
+func (ct *cartTest) remindedOK(t *testing.T, exp int) {
+	var got int
+	for _, to := range ct.Mailer.reminded {
+		if to == ct.UserEmail {
+			got++
+		}
+	}
+
+	if got != exp {
+		t.Fatalf("expected %d cart reminders to %s, got %d", exp, ct.UserEmail, got)
+	}
+}
+
+func (ct *cartTest) updatePreferencesOK(t *testing.T, reminders bool) {
 	if err := Login(ct.Server, ct.UserEmail, ct.UserPass); err != nil {
 		t.Fatal(err)
 	}
 	defer Logout(ct.Server)
 
+	up := user.PreferencesUp{CartReminders: &reminders}
+
+	body, err := json.Marshal(&up)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPut, ct.URL+"/users/current/preferences", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ct.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't update preferences: status code %s", w.Status)
+	}
+
+	var got user.User
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal updated user: %v", err)
+	}
+
+	if got.CartReminders != reminders {
+		t.Fatalf("expected cart reminders %t, got %t", reminders, got.CartReminders)
+	}
+}
+
+func (ct *cartTest) createItemOK(t *testing.T, courseID string) cart.Item {
+	return ct.createItemAs(t, ct.UserEmail, ct.UserPass, courseID)
+}
+
+func (ct *cartTest) createItemAs(t *testing.T, email string, pass string, courseID string) cart.Item {
+	if err := Login(ct.Server, email, pass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ct.Server)
+
 	c := cart.ItemNew{
 		CourseID: courseID,
 	}
@@ -84,6 +187,7 @@ func (ct *cartTest) createItemOK(t *testing.T, courseID string) cart.Item {
 
 	exp := got
 	exp.CourseID = c.CourseID
+	exp.PriceChanged = false
 
 	if diff := cmp.Diff(got, exp); diff != "" {
 		t.Fatalf("wrong course payload. Diff: \n%s", diff)
@@ -92,6 +196,65 @@ func (ct *cartTest) createItemOK(t *testing.T, courseID string) cart.Item {
 	return got
 }
 
+func (ct *cartTest) createGuestItemOK(t *testing.T, courseID string) cart.Item {
+	body, err := json.Marshal(cart.ItemNew{CourseID: courseID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPut, ct.URL+"/cart/guest/items", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ct.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusCreated {
+		t.Fatalf("can't create guest cart item: status code %s", w.Status)
+	}
+
+	var got cart.Item
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal created guest cart item: %v", err)
+	}
+
+	if got.CourseID != courseID {
+		t.Fatalf("expected guest cart item for course %s, got %s", courseID, got.CourseID)
+	}
+
+	return got
+}
+
+func (ct *cartTest) showGuestCartOK(t *testing.T, n int) {
+	r, err := http.NewRequest(http.MethodGet, ct.URL+"/cart/guest", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ct.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't show guest cart: status code %s", w.Status)
+	}
+
+	var got cart.Cart
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal guest cart: %v", err)
+	}
+
+	if len(got.Items) != n {
+		t.Fatalf("expected %d items in the guest cart, got %d", n, len(got.Items))
+	}
+}
+
 func (ct *cartTest) deleteItemOK(t *testing.T, courseID string) {
 	if err := Login(ct.Server, ct.UserEmail, ct.UserPass); err != nil {
 		t.Fatal(err)
@@ -178,3 +341,39 @@ func (ct *cartTest) showCartOK(t *testing.T, exp cart.Cart) {
 		t.Fatalf("wrong cart payload. Diff: \n%s", diff)
 	}
 }
+
+func (ct *cartTest) checkoutStale(t *testing.T, courseID string) {
+	if err := Login(ct.Server, ct.UserEmail, ct.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ct.Server)
+
+	body, err := json.Marshal(order.CheckoutNew{Currency: "USD", Country: "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, ct.URL+"/orders/stripe", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ct.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusConflict {
+		t.Fatalf("expected status code %d checking out a stale cart, got %s", http.StatusConflict, w.Status)
+	}
+
+	var got order.StaleCart
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal stale cart: %v", err)
+	}
+
+	if len(got.Courses) != 1 || got.Courses[0] != courseID {
+		t.Fatalf("expected course %s to be reported as stale, got %v", courseID, got.Courses)
+	}
+}
@@ -0,0 +1,376 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/irsalhamdi/e-commerce-video/core/cart"
+	"github.com/irsalhamdi/e-commerce-video/core/coupon"
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/core/order"
+	"github.com/irsalhamdi/e-commerce-video/money"
+)
+
+type couponTest struct {
+	*TestEnv
+}
+
+func TestCoupon(t *testing.T) {
+	env, err := NewTestEnv(t, "coupon_test")
+	if err != nil {
+		t.Fatalf("initializing test env: %v", err)
+	}
+
+	cpt := &couponTest{env}
+	ct := &courseTest{env}
+	rt := &cartTest{env}
+	ot := &orderTest{env}
+
+	c1 := ct.createCourseOK(t)
+	c2 := ct.createCourseOK(t)
+	c3 := ct.createCourseOK(t)
+
+	cpt.createCouponUnauth(t)
+
+	half := cpt.createCouponOK(t, coupon.CouponNew{
+		Code:       "half" + c1.ID[:8],
+		Kind:       coupon.Percentage,
+		Percentage: 50,
+		CourseIDs:  []string{c1.ID},
+		ExpiresAt:  time.Now().Add(time.Hour),
+	})
+
+	fixed := cpt.createCouponOK(t, coupon.CouponNew{
+		Code:           "fixed" + c1.ID[:8],
+		Kind:           coupon.Fixed,
+		Amounts:        money.Prices{"EUR": 500},
+		MaxRedemptions: 1,
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+
+	expired := cpt.createCouponOK(t, coupon.CouponNew{
+		Code:       "expired" + c1.ID[:8],
+		Kind:       coupon.Percentage,
+		Percentage: 10,
+		ExpiresAt:  time.Now().Add(time.Hour),
+	})
+	expired = cpt.updateCouponOK(t, expired, coupon.CouponUp{ExpiresAt: &time.Time{}})
+
+	cpt.listCouponsOK(t, 3)
+
+	rt.createItemOK(t, c1.ID)
+	rt.createItemOK(t, c2.ID)
+
+	cpt.applyCoupon(t, "inexistent", http.StatusNotFound)
+	cpt.applyCoupon(t, expired.Code, http.StatusUnprocessableEntity)
+	cpt.applyCoupon(t, half.Code, http.StatusOK)
+
+	ot.Paypal.expectedCart = []course.Course{c1, c2}
+	ot.Paypal.expectedCurrency = "USD"
+	ot.Paypal.expectedDiscount = c1.Prices["USD"] * 50 / 100
+	ot.testPaypal(t)
+
+	if got := cpt.showCouponOK(t, half.ID); got.Redemptions != 1 {
+		t.Fatalf("expected coupon[%s] to be redeemed once, got %d", got.ID, got.Redemptions)
+	}
+
+	rt.createItemOK(t, c3.ID)
+	cpt.applyCoupon(t, fixed.Code, http.StatusOK)
+
+	exp := 500
+	if c3.Prices["EUR"] < exp {
+		exp = c3.Prices["EUR"]
+	}
+
+	ot.Stripe.expectedCart = []course.Course{c3}
+	ot.Stripe.expectedCurrency = "EUR"
+	ot.Stripe.expectedDiscount = exp
+	id := ot.createStripe(t, order.CheckoutNew{Currency: "EUR", Country: "US"})
+
+	if got := cpt.showCouponOK(t, fixed.ID); got.Redemptions != 1 {
+		t.Fatalf("expected coupon[%s] to be reserved by the checkout, got %d redemptions", got.ID, got.Redemptions)
+	}
+
+	cpt.checkoutExhausted(t, order.CheckoutNew{Currency: "EUR", Recipient: "friend@example.com", Country: "US"})
+	ot.completeStripe(t, id)
+
+	if got := cpt.showCouponOK(t, fixed.ID); got.Redemptions != 1 {
+		t.Fatalf("expected coupon[%s] to be redeemed once, got %d", got.ID, got.Redemptions)
+	}
+
+	cpt.applyCoupon(t, fixed.Code, http.StatusUnprocessableEntity)
+
+	cpt.deleteCouponOK(t, expired.ID)
+	cpt.listCouponsOK(t, 2)
+}
+
+func (cpt *couponTest) createCouponOK(t *testing.T, c coupon.CouponNew) coupon.Coupon {
+	if err := Login(cpt.Server, cpt.AdminEmail, cpt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(cpt.Server)
+
+	body, err := json.Marshal(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, cpt.URL+"/coupons", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := cpt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusCreated {
+		t.Fatalf("can't create coupon: status code %s", w.Status)
+	}
+
+	var got coupon.Coupon
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal created coupon: %v", err)
+	}
+
+	if got.Kind != c.Kind || got.Percentage != c.Percentage || got.MaxRedemptions != c.MaxRedemptions {
+		t.Fatalf("wrong coupon payload: %+v", got)
+	}
+
+	return got
+}
+
+func (cpt *couponTest) createCouponUnauth(t *testing.T) {
+	if err := Login(cpt.Server, cpt.UserEmail, cpt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(cpt.Server)
+
+	c := coupon.CouponNew{
+		Code:       "unauth",
+		Kind:       coupon.Percentage,
+		Percentage: 10,
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+
+	body, err := json.Marshal(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, cpt.URL+"/coupons", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := cpt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status code %d, got %s", http.StatusUnauthorized, w.Status)
+	}
+}
+
+func (cpt *couponTest) updateCouponOK(t *testing.T, c coupon.Coupon, cup coupon.CouponUp) coupon.Coupon {
+	if err := Login(cpt.Server, cpt.AdminEmail, cpt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(cpt.Server)
+
+	body, err := json.Marshal(&cup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPut, cpt.URL+"/coupons/"+c.ID, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := cpt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't update coupon: status code %s", w.Status)
+	}
+
+	var got coupon.Coupon
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal updated coupon: %v", err)
+	}
+
+	exp := c
+	exp.CreatedAt = got.CreatedAt
+	exp.UpdatedAt = got.UpdatedAt
+	if cup.ExpiresAt != nil {
+		exp.ExpiresAt = cup.ExpiresAt.UTC()
+	}
+
+	if diff := cmp.Diff(got, exp, cmpopts.EquateEmpty()); diff != "" {
+		t.Fatalf("wrong coupon payload. Diff: \n%s", diff)
+	}
+
+	return got
+}
+
+func (cpt *couponTest) showCouponOK(t *testing.T, id string) coupon.Coupon {
+	if err := Login(cpt.Server, cpt.AdminEmail, cpt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(cpt.Server)
+
+	r, err := http.NewRequest(http.MethodGet, cpt.URL+"/coupons/"+id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := cpt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't show coupon: status code %s", w.Status)
+	}
+
+	var got coupon.Coupon
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal coupon: %v", err)
+	}
+
+	return got
+}
+
+func (cpt *couponTest) listCouponsOK(t *testing.T, n int) {
+	if err := Login(cpt.Server, cpt.AdminEmail, cpt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(cpt.Server)
+
+	r, err := http.NewRequest(http.MethodGet, cpt.URL+"/coupons", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := cpt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't list coupons: status code %s", w.Status)
+	}
+
+	var got []coupon.Coupon
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal coupons: %v", err)
+	}
+
+	if len(got) != n {
+		t.Fatalf("expected %d coupons, got %d", n, len(got))
+	}
+}
+
+func (cpt *couponTest) deleteCouponOK(t *testing.T, id string) {
+	if err := Login(cpt.Server, cpt.AdminEmail, cpt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(cpt.Server)
+
+	r, err := http.NewRequest(http.MethodDelete, cpt.URL+"/coupons/"+id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := cpt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusNoContent {
+		t.Fatalf("can't delete coupon: status code %s", w.Status)
+	}
+}
+
+func (cpt *couponTest) applyCoupon(t *testing.T, code string, status int) {
+	if err := Login(cpt.Server, cpt.UserEmail, cpt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(cpt.Server)
+
+	body, err := json.Marshal(coupon.Apply{Code: code})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPut, cpt.URL+"/cart/coupon", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := cpt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d applying coupon %s, got %s", status, code, w.Status)
+	}
+
+	if status != http.StatusOK {
+		return
+	}
+
+	var got cart.Cart
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal cart: %v", err)
+	}
+
+	if got.CouponID == nil {
+		t.Fatalf("expected coupon %s to be applied to the cart", code)
+	}
+}
+
+func (cpt *couponTest) checkoutExhausted(t *testing.T, cn order.CheckoutNew) {
+	if err := Login(cpt.Server, cpt.UserEmail, cpt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(cpt.Server)
+
+	body, err := json.Marshal(cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, cpt.URL+"/orders/stripe", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := cpt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("checkout with a coupon reserved by another one should fail: status code %s", w.Status)
+	}
+}
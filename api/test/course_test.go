@@ -12,6 +12,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/money"
 	"github.com/irsalhamdi/e-commerce-video/validate"
 )
 
@@ -44,6 +45,10 @@ func TestCourse(t *testing.T) {
 }
 
 func (ct *courseTest) createCourseOK(t *testing.T) course.Course {
+	return ct.createCourseWithPricesOK(t, money.Prices{"USD": rand.Intn(100000), "EUR": rand.Intn(100000)})
+}
+
+func (ct *courseTest) createCourseWithPricesOK(t *testing.T, prices money.Prices) course.Course {
 	if err := Login(ct.Server, ct.AdminEmail, ct.AdminPass); err != nil {
 		t.Fatal(err)
 	}
@@ -52,7 +57,7 @@ func (ct *courseTest) createCourseOK(t *testing.T) course.Course {
 	c := course.CourseNew{
 		Name:        "Test" + strconv.Itoa(rand.Intn(1000)),
 		Description: "This is a test course",
-		Price:       rand.Intn(1000),
+		Prices:      prices,
 		ImageURL:    "/images/test.png",
 	}
 
@@ -84,7 +89,7 @@ func (ct *courseTest) createCourseOK(t *testing.T) course.Course {
 	exp := got
 	exp.Name = c.Name
 	exp.Description = c.Description
-	exp.Price = c.Price
+	exp.Prices = c.Prices
 	exp.ImageURL = c.ImageURL
 
 	if diff := cmp.Diff(got, exp); diff != "" {
@@ -103,7 +108,7 @@ func (ct *courseTest) createCourseUnauth(t *testing.T) {
 	c := course.CourseNew{
 		Name:        "Test",
 		Description: "This is a test course",
-		Price:       100,
+		Prices:      money.Prices{"USD": 10000},
 		ImageURL:    "/images/test.png",
 	}
 
@@ -141,7 +146,7 @@ func (ct *courseTest) updateCourseOK(t *testing.T, crs course.Course) course.Cou
 	c := course.CourseUp{
 		Name:        ptr("Updated Test"),
 		Description: ptr("This is an updated test course"),
-		Price:       ptr(500),
+		Prices:      money.Prices{"USD": 50000},
 		ImageURL:    ptr("/images/updated.png"),
 	}
 
@@ -173,7 +178,7 @@ func (ct *courseTest) updateCourseOK(t *testing.T, crs course.Course) course.Cou
 	exp := got
 	exp.Name = *c.Name
 	exp.Description = *c.Description
-	exp.Price = *c.Price
+	exp.Prices = c.Prices
 	exp.ImageURL = *c.ImageURL
 
 	if diff := cmp.Diff(got, exp); diff != "" {
@@ -194,7 +199,7 @@ func (ct *courseTest) updateCourseConcurrent(t *testing.T, crs course.Course) {
 		c := course.CourseUp{
 			Name:        ptr("Updated Test"),
 			Description: ptr("This is an updated test course"),
-			Price:       ptr(500),
+			Prices:      money.Prices{"USD": 50000},
 			ImageURL:    ptr("/images/updated.png"),
 		}
 
@@ -242,7 +247,7 @@ func (ct *courseTest) updateCourseInexistent(t *testing.T, crs course.Course) {
 	c := course.CourseUp{
 		Name:        ptr("Updated Test Course Not Existent"),
 		Description: ptr("This is an updated test course - not exist"),
-		Price:       ptr(300),
+		Prices:      money.Prices{"USD": 30000},
 		ImageURL:    ptr("/images/updated.png"),
 	}
 
@@ -276,7 +281,7 @@ func (ct *courseTest) updateCourseUnauth(t *testing.T, crs course.Course) {
 	c := course.CourseUp{
 		Name:        ptr("Updated Test Unauth"),
 		Description: ptr("This is an updated test course - unauth"),
-		Price:       ptr(300),
+		Prices:      money.Prices{"USD": 30000},
 		ImageURL:    ptr("/images/updated.png"),
 	}
 
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/core/order"
+	"github.com/irsalhamdi/e-commerce-video/core/user"
+)
+
+type giftTest struct {
+	*TestEnv
+}
+
+func TestGift(t *testing.T) {
+	env, err := NewTestEnv(t, "gift_test")
+	if err != nil {
+		t.Fatalf("initializing test env: %v", err)
+	}
+
+	gt := &giftTest{env}
+	ct := &courseTest{env}
+	rt := &cartTest{env}
+	ot := &orderTest{env}
+
+	c1 := ct.createCourseOK(t)
+	c2 := ct.createCourseOK(t)
+
+	friend := user.UserSignup{
+		Name:            "Friend",
+		Email:           "friend@gift.com",
+		Password:        "friendpass",
+		PasswordConfirm: "friendpass",
+	}
+
+	rt.createItemOK(t, c1.ID)
+	ot.Paypal.expectedCart = []course.Course{c1}
+	ot.Paypal.expectedCurrency = "USD"
+	ot.checkoutPaypal(t, order.CheckoutNew{Currency: "USD", Recipient: friend.Email, Country: "US"})
+
+	tok := gt.waitGiftToken(t, "")
+	ct.listCoursesOwnedOK(t, []course.Course{})
+
+	gt.claimGift(t, tok, http.StatusAccepted)
+
+	if _, err := Signup(gt.Server, friend); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Activate(gt.Server, friend.Email, gt.Mailer); err != nil {
+		t.Fatal(err)
+	}
+	Logout(gt.Server)
+
+	gt.ownedCourses(t, friend.Email, friend.Password, 1)
+
+	if err := Login(gt.Server, friend.Email, friend.Password); err != nil {
+		t.Fatal(err)
+	}
+	gt.claimGift(t, tok, http.StatusUnprocessableEntity)
+	Logout(gt.Server)
+
+	rt.createItemOK(t, c2.ID)
+	ot.Paypal.expectedCart = []course.Course{c2}
+	ot.checkoutPaypal(t, order.CheckoutNew{Currency: "USD", Recipient: friend.Email, Country: "US"})
+
+	gt.ownedCourses(t, friend.Email, friend.Password, 2)
+	ct.listCoursesOwnedOK(t, []course.Course{})
+}
+
+func (gt *giftTest) waitGiftToken(t *testing.T, prev string) string {
+	for i := 0; i < 100; i++ {
+		if gt.Mailer.token != prev {
+			return gt.Mailer.token
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("gift token was not sent")
+	return ""
+}
+
+func (gt *giftTest) claimGift(t *testing.T, tok string, status int) {
+	body, err := json.Marshal(order.GiftClaim{Token: tok})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, gt.URL+"/gifts/claim", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := gt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d claiming gift, got %s", status, w.Status)
+	}
+}
+
+func (gt *giftTest) ownedCourses(t *testing.T, email string, pass string, n int) {
+	if err := Login(gt.Server, email, pass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(gt.Server)
+
+	r, err := http.NewRequest(http.MethodGet, gt.URL+"/courses/owned", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := gt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't fetch owned courses: status code %s", w.Status)
+	}
+
+	var got []course.Course
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal owned courses: %v", err)
+	}
+
+	if len(got) != n {
+		t.Fatalf("expected %s to own %d courses, got %d", email, n, len(got))
+	}
+}
@@ -17,7 +17,10 @@ import (
 	"github.com/irsalhamdi/e-commerce-video/api"
 	"github.com/irsalhamdi/e-commerce-video/api/background"
 	"github.com/irsalhamdi/e-commerce-video/config"
+	"github.com/irsalhamdi/e-commerce-video/core/auth"
+	"github.com/irsalhamdi/e-commerce-video/core/order"
 	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/irsalhamdi/e-commerce-video/tax"
 	"github.com/jmoiron/sqlx"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
@@ -111,7 +114,9 @@ func startDB(user string, pass string, dbname string) (*dockertest.Resource, fun
 }
 
 type mockMailer struct {
-	token string
+	token    string
+	reminded []string
+	failing  string
 }
 
 func (m *mockMailer) SendActivationToken(token string, dst string) error {
@@ -124,6 +129,24 @@ func (m *mockMailer) SendRecoveryToken(token string, dst string) error {
 	return nil
 }
 
+func (m *mockMailer) SendLoginToken(token string, dst string) error {
+	m.token = token
+	return nil
+}
+
+func (m *mockMailer) SendGiftToken(token string, dst string) error {
+	m.token = token
+	return nil
+}
+
+func (m *mockMailer) SendCartReminder(to string, name string, items []string) error {
+	if to == m.failing {
+		return fmt.Errorf("mailbox %s unavailable", to)
+	}
+	m.reminded = append(m.reminded, to)
+	return nil
+}
+
 const seedTest = `
 INSERT INTO users (user_id, name, email, role, active, password_hash, created_at, updated_at) VALUES
 	('ae127240-ce13-4789-aafd-d2f31e7ee487', 'Admin', '{{ .AdminEmail}}', 'ADMIN', TRUE, '{{ .AdminPassHash}}', '2022-09-16 00:00:00', '2022-09-16 00:00:00'),
@@ -140,9 +163,11 @@ type TestEnv struct {
 	UserEmail string
 	UserPass  string
 
+	DB            *sqlx.DB
 	Mailer        *mockMailer
 	Paypal        *mockPaypal
 	Stripe        *mockStripe
+	Oauth         *mockOauth
 	WebhookSecret string
 }
 
@@ -225,6 +250,8 @@ func NewTestEnv(t *testing.T, dbname string) (*TestEnv, error) {
 	sess := scs.New()
 	sess.Lifetime = 24 * time.Hour
 
+	te.DB = dbEnv
+
 	mail := &mockMailer{}
 	te.Mailer = mail
 
@@ -242,10 +269,11 @@ func NewTestEnv(t *testing.T, dbname string) (*TestEnv, error) {
 	strpserver := httptest.NewServer(te.Stripe.handle())
 
 	strpcfg := config.Stripe{
-		APISecret:     "random-key",
-		WebhookSecret: "random-test-secret",
-		SuccessURL:    "/success.html",
-		CancelURL:     "/cart.html",
+		APISecret:      "random-key",
+		WebhookSecret:  "random-test-secret",
+		MonthlyPriceID: "price_monthly",
+		SuccessURL:     "/success.html",
+		CancelURL:      "/cart.html",
 	}
 	te.WebhookSecret = strpcfg.WebhookSecret
 	strp := &stripecl.API{}
@@ -256,18 +284,53 @@ func NewTestEnv(t *testing.T, dbname string) (*TestEnv, error) {
 		Uploads: stripe.GetBackend(stripe.UploadsBackend),
 	})
 
+	stripeProv := order.NewStripe(strp, strpcfg)
+
+	te.Oauth = &mockOauth{}
+	oauthserver := httptest.NewServer(te.Oauth.handle())
+
+	provs, err := auth.MakeProviders(context.Background(), []auth.ProviderConfig{{
+		Name:        "mock",
+		Client:      "test",
+		Secret:      "test",
+		AuthURL:     oauthserver.URL + "/authorize",
+		TokenURL:    oauthserver.URL + "/token",
+		UserInfoURL: oauthserver.URL + "/user",
+		EmailsURL:   oauthserver.URL + "/emails",
+		RedirectURL: "/auth/oauth-callback/mock",
+		Claims:      auth.ClaimMapping{Name: []string{"name", "login"}},
+	}, {
+		Name:        "mock-trusting",
+		Client:      "test",
+		Secret:      "test",
+		AuthURL:     oauthserver.URL + "/authorize",
+		TokenURL:    oauthserver.URL + "/token",
+		UserInfoURL: oauthserver.URL + "/user",
+		RedirectURL: "/auth/oauth-callback/mock-trusting",
+		Claims:      auth.ClaimMapping{Name: []string{"name", "login"}, SkipVerification: true},
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the oauth providers: %w", err)
+	}
+
 	api := api.APIMux(api.APIConfig{
-		CorsOrigin:         "",
-		Log:                log,
-		DB:                 dbEnv,
-		Session:            sess,
-		Mailer:             mail,
-		TokenTimeout:       time.Nanosecond,
-		Background:         bg,
-		Paypal:             pp,
-		Stripe:             strp,
-		StripeCfg:          strpcfg,
+		CorsOrigin:   "",
+		Log:          log,
+		DB:           dbEnv,
+		Session:      sess,
+		Mailer:       mail,
+		TokenTimeout: time.Nanosecond,
+		Background:   bg,
+		Payments: map[string]order.PaymentProvider{
+			order.ProviderPaypal: order.NewPaypal(pp, "webhook"),
+			order.ProviderStripe: stripeProv,
+		},
+		Subscriptions:      stripeProv,
+		TaxRates:           tax.Rates{"IT": 2200},
+		Providers:          provs,
+		LoginRedirectURL:   "/dashboard",
 		ActivationRequired: true,
+		UserCacheTTL:       time.Nanosecond,
 	})
 
 	jar, err := cookiejar.New(nil)
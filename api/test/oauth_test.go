@@ -0,0 +1,185 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/irsalhamdi/e-commerce-video/api/web"
+	"github.com/irsalhamdi/e-commerce-video/core/user"
+)
+
+// mockOauth is a plain oauth2 provider sending an unverified email in the
+// user info and the verified ones in the emails list, like GitHub does.
+type mockOauth struct {
+	login      string
+	email      string
+	unverified bool
+}
+
+func (m *mockOauth) handle() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "test-code" {
+			web.Respond(context.Background(), w, nil, http.StatusBadRequest)
+			return
+		}
+		web.Respond(context.Background(), w, map[string]any{"access_token": "test-token", "token_type": "bearer"}, http.StatusOK)
+	})
+
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			web.Respond(context.Background(), w, nil, http.StatusUnauthorized)
+			return
+		}
+		web.Respond(context.Background(), w, map[string]any{"login": m.login, "name": nil, "email": m.email}, http.StatusOK)
+	})
+
+	mux.HandleFunc("/emails", func(w http.ResponseWriter, r *http.Request) {
+		emails := []map[string]any{
+			{"email": "other@oauth.com", "primary": false, "verified": true},
+			{"email": m.email, "primary": true, "verified": !m.unverified},
+		}
+		web.Respond(context.Background(), w, emails, http.StatusOK)
+	})
+
+	return mux
+}
+
+type oauthTest struct {
+	*TestEnv
+}
+
+func TestOauth(t *testing.T) {
+	env, err := NewTestEnv(t, "oauth_test")
+	if err != nil {
+		t.Fatalf("initializing test env: %v", err)
+	}
+
+	ot := &oauthTest{env}
+	ot.Oauth.login = "jdoe"
+	ot.Oauth.email = "jdoe@oauth.com"
+
+	ot.oauthLoginNotFound(t)
+	ot.oauthLoginUnverified(t)
+	ot.oauthLoginOK(t)
+	ot.oauthLinkUnverified(t)
+}
+
+func (ot *oauthTest) oauthLoginNotFound(t *testing.T) {
+	w, err := ot.Client().Get(ot.URL + "/auth/oauth-login/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected unknown provider to be not found: status code %s", w.Status)
+	}
+}
+
+// callback goes through the oauth login of the provider and returns the
+// callback response.
+func (ot *oauthTest) callback(t *testing.T, provider string) *http.Response {
+	w, err := ot.Client().Get(ot.URL + "/auth/oauth-login/" + provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't start oauth login: status code %s", w.Status)
+	}
+
+	var raw string
+	if err := json.NewDecoder(w.Body).Decode(&raw); err != nil {
+		t.Fatalf("cannot unmarshal auth url: %v", err)
+	}
+
+	authURL, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := make(url.Values)
+	q.Set("state", authURL.Query().Get("state"))
+	q.Set("code", "test-code")
+
+	w, err = ot.Client().Get(ot.URL + "/auth/oauth-callback/" + provider + "?" + q.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Body.Close()
+
+	return w
+}
+
+func (ot *oauthTest) oauthLoginUnverified(t *testing.T) {
+	ot.Oauth.unverified = true
+	defer func() { ot.Oauth.unverified = false }()
+
+	w := ot.callback(t, "mock")
+	if w.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("oauth login with an unverified email should fail: status code %s", w.Status)
+	}
+
+	w, err := ot.Client().Get(ot.URL + "/users/current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unverified oauth login should not log in: status code %s", w.Status)
+	}
+}
+
+func (ot *oauthTest) oauthLoginOK(t *testing.T) {
+	w := ot.callback(t, "mock")
+	if w.StatusCode != http.StatusFound || w.Header.Get("Location") != "/dashboard" {
+		t.Fatalf("oauth callback should redirect to the dashboard: status code %s", w.Status)
+	}
+
+	w, err := ot.Client().Get(ot.URL + "/users/current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't show current user: status code %s", w.Status)
+	}
+
+	var got user.User
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal current user: %v", err)
+	}
+
+	if got.Name != ot.Oauth.login || got.Email != ot.Oauth.email {
+		t.Fatalf("wrong user created by oauth login: %+v", got)
+	}
+}
+
+func (ot *oauthTest) oauthLinkUnverified(t *testing.T) {
+	if err := Logout(ot.Server); err != nil {
+		t.Fatal(err)
+	}
+
+	w := ot.callback(t, "mock-trusting")
+	if w.StatusCode != http.StatusConflict {
+		t.Fatalf("unverified email should not log in to an existing user: status code %s", w.Status)
+	}
+
+	w, err := ot.Client().Get(ot.URL + "/users/current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("unverified oauth login should not log in: status code %s", w.Status)
+	}
+}
@@ -6,10 +6,14 @@ import (
 	"io"
 	"net/http"
 	"path"
+	"sort"
 	"testing"
 	"time"
 
 	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/core/order"
+	"github.com/irsalhamdi/e-commerce-video/money"
+	"github.com/irsalhamdi/e-commerce-video/tax"
 	"github.com/plutov/paypal/v4"
 	"github.com/stripe/stripe-go/v74"
 	"github.com/stripe/stripe-go/v74/webhook"
@@ -38,10 +42,14 @@ func TestOrder(t *testing.T) {
 
 	ct.listCoursesOwnedOK(t, []course.Course{})
 
+	ot.checkoutUnknownProvider(t)
+
 	rt.createItemOK(t, c1.ID)
 	rt.createItemOK(t, c2.ID)
+	ot.checkoutWithoutCountry(t)
 
 	ot.Paypal.expectedCart = []course.Course{c1, c2}
+	ot.Paypal.expectedCurrency = "USD"
 	ot.testPaypal(t)
 
 	ct.listCoursesOwnedOK(t, []course.Course{c1, c2})
@@ -50,18 +58,88 @@ func TestOrder(t *testing.T) {
 	rt.createItemOK(t, c4.ID)
 
 	ot.Stripe.expectedCart = []course.Course{c3, c4}
+	ot.Stripe.expectedCurrency = "EUR"
 	ot.testStripe(t)
 
 	ct.listCoursesOwnedOK(t, []course.Course{c1, c2, c3, c4})
+
+	orders := ot.listOrdersOK(t, 2, 2)
+	ot.showReceiptOK(t, orders[0])
+	ot.listAllOrdersOK(t, "?status=success", 2)
+
+	ot.testStripeRefund(t)
+	ot.testStripeRefundedPaid(t)
+
+	ct.listCoursesOwnedOK(t, []course.Course{c1, c2})
+	ot.listAllOrdersOK(t, "?status=refunded", 1)
+
+	for _, o := range orders {
+		if o.Provider == order.ProviderPaypal {
+			ot.refund(t, o.ID, http.StatusNoContent)
+			ot.refund(t, o.ID, http.StatusUnprocessableEntity)
+		}
+	}
+
+	ct.listCoursesOwnedOK(t, []course.Course{})
+	ot.listAllOrdersOK(t, "?status=refunded", 2)
+
+	c5 := ct.createCourseWithPricesOK(t, money.Prices{"USD": 0})
+	rt.createItemOK(t, c5.ID)
+	rt.createItemOK(t, c1.ID)
+	ot.freeCheckout(t, http.StatusUnprocessableEntity)
+
+	rt.deleteItemOK(t, c1.ID)
+	ot.freeCheckout(t, http.StatusCreated)
+	ct.listCoursesOwnedOK(t, []course.Course{c5})
+
+	rt.createItemOK(t, c1.ID)
+	ot.Paypal.expectedCart = []course.Course{c1}
+	ot.Paypal.expectedDiscount = 0
+	ot.testPaypalWebhook(t)
+	ct.listCoursesOwnedOK(t, []course.Course{c1, c5})
+
+	rt.createItemOK(t, c2.ID)
+	ot.Paypal.expectedCart = []course.Course{c2}
+	ot.checkoutPaypal(t, order.CheckoutNew{Currency: "USD", Country: "IT"})
+
+	rt.createItemOK(t, c3.ID)
+	ot.Stripe.expectedCart = []course.Course{c3}
+	ot.checkoutStripe(t, order.CheckoutNew{Currency: "EUR", Country: "it"})
+
+	ot.invoicedOrdersOK(t, 2200, 2)
+
+	rt.createItemOK(t, c4.ID)
+	ot.Stripe.expectedCart = []course.Course{c4}
+	ot.Stripe.expectedCurrency = "EUR"
+	id := ot.createStripe(t, order.CheckoutNew{Currency: "EUR", Country: "US"})
+
+	ot.Paypal.expectedCart = []course.Course{c4}
+	ot.checkoutPaypal(t, order.CheckoutNew{Currency: "USD", Country: "US"})
+	if n := len(ot.Stripe.expired); n == 0 || ot.Stripe.expired[n-1] != id {
+		t.Fatalf("expected the pending stripe checkout %s to be canceled", id)
+	}
+	ot.listAllOrdersOK(t, "?review=true", 0)
+
+	ot.completeStripe(t, id)
+	ot.listAllOrdersOK(t, "?review=true", 1)
 }
 
 func (ot *orderTest) testPaypal(t *testing.T) {
+	ot.checkoutPaypal(t, order.CheckoutNew{Currency: ot.Paypal.expectedCurrency, Country: "US"})
+}
+
+func (ot *orderTest) checkoutPaypal(t *testing.T, cn order.CheckoutNew) {
 	if err := Login(ot.Server, ot.UserEmail, ot.UserPass); err != nil {
 		t.Fatal(err)
 	}
 	defer Logout(ot.Server)
 
-	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/paypal", nil)
+	body, err := json.Marshal(cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/paypal", bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -97,13 +175,107 @@ func (ot *orderTest) testPaypal(t *testing.T) {
 	}
 }
 
+func (ot *orderTest) testPaypalWebhook(t *testing.T) {
+	if err := Login(ot.Server, ot.UserEmail, ot.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ot.Server)
+
+	body, err := json.Marshal(order.CheckoutNew{Currency: ot.Paypal.expectedCurrency, Country: "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/paypal", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ot.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't create paypal order: status code %s", w.Status)
+	}
+
+	var ord paypal.Order
+	if err := json.NewDecoder(w.Body).Decode(&ord); err != nil {
+		t.Fatalf("cannot unmarshal paypal order: %v", err)
+	}
+
+	approved := map[string]any{"id": ord.ID, "status": "APPROVED"}
+	ot.sendPaypalEvent(t, "bad-signature", "WH-A-"+ord.ID, paypal.EventCheckoutOrderApproved, approved, http.StatusBadRequest)
+	ot.sendPaypalEvent(t, paypalSignature, "WH-A-"+ord.ID, paypal.EventCheckoutOrderApproved, approved, http.StatusNoContent)
+
+	completed := map[string]any{
+		"id":                 "capture-" + ord.ID,
+		"status":             "COMPLETED",
+		"supplementary_data": map[string]any{"related_ids": map[string]any{"order_id": ord.ID}},
+	}
+	ot.sendPaypalEvent(t, paypalSignature, "WH-C-"+ord.ID, paypal.EventPaymentCaptureCompleted, completed, http.StatusNoContent)
+}
+
+func (ot *orderTest) sendPaypalEvent(t *testing.T, sig string, id string, typ string, resource map[string]any, status int) {
+	raw, err := json.Marshal(resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt := paypal.AnyEvent{
+		Event: paypal.Event{
+			ID:        id,
+			EventType: typ,
+		},
+		Resource: raw,
+	}
+
+	b, err := json.Marshal(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/paypal/capture", bytes.NewBuffer(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("PAYPAL-TRANSMISSION-ID", id)
+	r.Header.Set("PAYPAL-TRANSMISSION-SIG", sig)
+
+	w, err := ot.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d sending paypal event %s, got %s", status, typ, w.Status)
+	}
+}
+
 func (ot *orderTest) testStripe(t *testing.T) {
+	ot.checkoutStripe(t, order.CheckoutNew{Currency: ot.Stripe.expectedCurrency, Country: "US"})
+}
+
+func (ot *orderTest) checkoutStripe(t *testing.T, cn order.CheckoutNew) {
+	id := ot.createStripe(t, cn)
+	ot.completeStripe(t, id)
+}
+
+func (ot *orderTest) createStripe(t *testing.T, cn order.CheckoutNew) string {
 	if err := Login(ot.Server, ot.UserEmail, ot.UserPass); err != nil {
 		t.Fatal(err)
 	}
 	defer Logout(ot.Server)
 
-	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/stripe", nil)
+	body, err := json.Marshal(cn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/stripe", bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -128,19 +300,60 @@ func (ot *orderTest) testStripe(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	id := path.Base(url)
+	ot.Stripe.sessions = append(ot.Stripe.sessions, id)
+
+	return id
+}
+
+func (ot *orderTest) completeStripe(t *testing.T, id string) {
+	obj := map[string]any{
+		"id":             id,
+		"mode":           stripe.CheckoutSessionModePayment,
+		"payment_intent": "pi_" + id,
+	}
+
+	ot.sendStripeEvent(t, "evt_completed_"+id, "checkout.session.completed", obj)
+	ot.sendStripeEvent(t, "evt_completed_"+id, "checkout.session.completed", obj)
+	ot.sendStripeEvent(t, "evt_retried_"+id, "checkout.session.completed", obj)
+}
+
+func (ot *orderTest) testStripeRefund(t *testing.T) {
+	id := ot.Stripe.sessions[len(ot.Stripe.sessions)-1]
+
 	obj := map[string]any{
-		"id":   path.Base(url),
-		"mode": stripe.CheckoutSessionModePayment,
+		"id":             "ch_" + id,
+		"refunded":       true,
+		"payment_intent": "pi_" + id,
 	}
 
+	ot.sendStripeEvent(t, "evt_refunded_"+id, "charge.refunded", obj)
+}
+
+// testStripeRefundedPaid checks that a payment event for a refunded order is
+// acknowledged instead of being retried forever.
+func (ot *orderTest) testStripeRefundedPaid(t *testing.T) {
+	id := ot.Stripe.sessions[len(ot.Stripe.sessions)-1]
+
+	obj := map[string]any{
+		"id":             id,
+		"mode":           stripe.CheckoutSessionModePayment,
+		"payment_intent": "pi_" + id,
+	}
+
+	ot.sendStripeEvent(t, "evt_late_"+id, "checkout.session.completed", obj)
+}
+
+func (ot *orderTest) sendStripeEvent(t *testing.T, id string, typ string, obj map[string]any) {
 	raw, err := json.Marshal(obj)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	evt := stripe.Event{
+		ID:         id,
 		APIVersion: "2022-11-15",
-		Type:       "checkout.session.completed",
+		Type:       typ,
 		Data: &stripe.EventData{
 			Raw: json.RawMessage(raw),
 		},
@@ -157,19 +370,310 @@ func (ot *orderTest) testStripe(t *testing.T) {
 		Timestamp: time.Now(),
 	})
 
-	r, err = http.NewRequest(http.MethodPost, ot.URL+"/orders/stripe/capture", bytes.NewBuffer(b))
+	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/stripe/capture", bytes.NewBuffer(b))
 	if err != nil {
 		t.Fatal(err)
 	}
 	r.Header.Set("Stripe-Signature", signed.Header)
 
-	w, err = ot.Client().Do(r)
+	w, err := ot.Client().Do(r)
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer w.Body.Close()
 
 	if w.StatusCode != http.StatusNoContent {
-		t.Fatalf("can't trigger stripe webhook: status code %s", w.Status)
+		t.Fatalf("can't trigger stripe webhook %s: status code %s", typ, w.Status)
+	}
+}
+
+func (ot *orderTest) listOrdersOK(t *testing.T, n int, items int) []order.Order {
+	if err := Login(ot.Server, ot.UserEmail, ot.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ot.Server)
+
+	r, err := http.NewRequest(http.MethodGet, ot.URL+"/orders", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ot.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't list orders: status code %s", w.Status)
+	}
+
+	var got []order.Order
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal orders: %v", err)
+	}
+
+	if len(got) != n {
+		t.Fatalf("expected %d orders, got %d", n, len(got))
+	}
+
+	for _, o := range got {
+		if o.Status != order.Success {
+			t.Fatalf("expected order[%s] to be successful, got %s", o.ID, o.Status)
+		}
+
+		if len(o.Items) != items {
+			t.Fatalf("expected order[%s] to have %d items, got %d", o.ID, items, len(o.Items))
+		}
+	}
+
+	return got
+}
+
+func (ot *orderTest) listAllOrdersOK(t *testing.T, query string, n int) {
+	if err := Login(ot.Server, ot.AdminEmail, ot.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ot.Server)
+
+	r, err := http.NewRequest(http.MethodGet, ot.URL+"/orders/all"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ot.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't list all orders: status code %s", w.Status)
+	}
+
+	var got []order.Order
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal orders: %v", err)
+	}
+
+	if len(got) != n {
+		t.Fatalf("expected %d orders with filter %s, got %d", n, query, len(got))
+	}
+}
+
+func (ot *orderTest) showReceiptOK(t *testing.T, ord order.Order) {
+	if err := Login(ot.Server, ot.UserEmail, ot.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ot.Server)
+
+	r, err := http.NewRequest(http.MethodGet, ot.URL+"/orders/"+ord.ID+"/receipt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ot.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't download receipt: status code %s", w.Status)
+	}
+
+	b, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(b, []byte(ord.ID)) {
+		t.Fatalf("receipt does not mention order %s", ord.ID)
+	}
+}
+
+func (ot *orderTest) refund(t *testing.T, id string, status int) {
+	if err := Login(ot.Server, ot.AdminEmail, ot.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ot.Server)
+
+	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/"+id+"/refund", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ot.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d refunding order, got %s", status, w.Status)
+	}
+}
+
+func (ot *orderTest) freeCheckout(t *testing.T, status int) {
+	if err := Login(ot.Server, ot.UserEmail, ot.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ot.Server)
+
+	body, err := json.Marshal(order.CheckoutNew{Currency: "USD", Country: "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/free", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ot.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d on free checkout, got %s", status, w.Status)
+	}
+
+	if status != http.StatusCreated {
+		return
+	}
+
+	var got order.Order
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal free order: %v", err)
+	}
+
+	if got.Status != order.Success || got.Provider != order.ProviderFree || len(got.Items) != 1 {
+		t.Fatalf("wrong free order payload: %+v", got)
+	}
+}
+
+func (ot *orderTest) checkoutUnknownProvider(t *testing.T) {
+	if err := Login(ot.Server, ot.UserEmail, ot.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ot.Server)
+
+	body, err := json.Marshal(order.CheckoutNew{Currency: "USD", Country: "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/unknown", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ot.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status code %d, got %s", http.StatusNotFound, w.Status)
+	}
+}
+
+func (ot *orderTest) invoicedOrdersOK(t *testing.T, rate int, taxed int) {
+	if err := Login(ot.Server, ot.UserEmail, ot.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ot.Server)
+
+	r, err := http.NewRequest(http.MethodGet, ot.URL+"/orders", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ot.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't list orders: status code %s", w.Status)
+	}
+
+	var got []order.Order
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal orders: %v", err)
+	}
+
+	var numbers []int64
+	var n int
+	for _, o := range got {
+		if o.Provider == order.ProviderFree {
+			if o.InvoiceNumber != nil {
+				t.Fatalf("expected free order[%s] to have no invoice", o.ID)
+			}
+			continue
+		}
+
+		if o.InvoiceNumber == nil {
+			t.Fatalf("expected order[%s] to have an invoice", o.ID)
+		}
+		numbers = append(numbers, *o.InvoiceNumber)
+
+		if o.TaxRate == 0 {
+			continue
+		}
+		n++
+
+		if o.TaxRate != rate {
+			t.Fatalf("expected order[%s] tax rate %d, got %d", o.ID, rate, o.TaxRate)
+		}
+
+		for _, it := range o.Items {
+			net, tx := tax.Split(it.Amount, rate)
+			if it.Net != net || it.Tax != tx {
+				t.Fatalf("wrong tax breakdown of item %+v", it)
+			}
+		}
+	}
+
+	if n != taxed {
+		t.Fatalf("expected %d taxed orders, got %d", taxed, n)
+	}
+
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	for i, num := range numbers {
+		if num != int64(i+1) {
+			t.Fatalf("expected sequential invoice numbers, got %v", numbers)
+		}
+	}
+}
+
+func (ot *orderTest) checkoutWithoutCountry(t *testing.T) {
+	if err := Login(ot.Server, ot.UserEmail, ot.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(ot.Server)
+
+	body, err := json.Marshal(order.CheckoutNew{Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, ot.URL+"/orders/paypal", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ot.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected checkout without billing country to be rejected: status code %s", w.Status)
 	}
 }
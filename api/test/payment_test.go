@@ -7,16 +7,24 @@ import (
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/irsalhamdi/e-commerce-video/api/web"
+	"github.com/irsalhamdi/e-commerce-video/core/bundle"
 	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/money"
 	"github.com/plutov/paypal/v4"
 	mock "github.com/stripe/stripe-mock/param"
 )
 
+const paypalSignature = "valid-signature"
+
 type mockPaypal struct {
-	expectedCart []course.Course
+	expectedCart     []course.Course
+	expectedBundles  []bundle.Bundle
+	expectedCurrency string
+	expectedDiscount int
 }
 
 func (m *mockPaypal) handle() http.Handler {
@@ -34,17 +42,25 @@ func (m *mockPaypal) handle() http.Handler {
 			return
 		}
 
-		if len(pu.Units[0].Items) != len(m.expectedCart) {
+		if len(pu.Units[0].Items) != len(m.expectedCart)+len(m.expectedBundles) {
 			web.Respond(context.Background(), w, nil, 400)
 			return
 		}
 
-		var tot int
+		tot := -m.expectedDiscount
 		for _, c := range m.expectedCart {
-			tot += c.Price
+			tot += c.Prices[m.expectedCurrency]
+		}
+		for _, b := range m.expectedBundles {
+			tot += b.Prices[m.expectedCurrency]
+		}
+
+		if pu.Units[0].Amount.Currency != m.expectedCurrency {
+			web.Respond(context.Background(), w, nil, 400)
+			return
 		}
 
-		if pu.Units[0].Amount.Value != strconv.Itoa(tot) {
+		if pu.Units[0].Amount.Value != money.Format(tot, m.expectedCurrency) {
 			web.Respond(context.Background(), w, nil, 400)
 			return
 		}
@@ -55,18 +71,57 @@ func (m *mockPaypal) handle() http.Handler {
 	})
 
 	capture := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ord := paypal.Order{Status: "COMPLETED"}
+		id := mux.Vars(r)["id"]
+		ord := paypal.CaptureOrderResponse{
+			ID:     id,
+			Status: "COMPLETED",
+			PurchaseUnits: []paypal.CapturedPurchaseUnit{{
+				Payments: &paypal.CapturedPayments{
+					Captures: []paypal.CaptureAmount{{ID: "capture-" + id, Status: "COMPLETED"}},
+				},
+			}},
+		}
 		web.Respond(context.Background(), w, ord, 200)
 	})
 
+	refund := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ref := paypal.RefundResponse{ID: "refund-" + mux.Vars(r)["id"], Status: "COMPLETED"}
+		web.Respond(context.Background(), w, ref, 201)
+	})
+
+	verify := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			TransmissionSig string `json:"transmission_sig"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			web.Respond(context.Background(), w, nil, 400)
+			return
+		}
+
+		status := "FAILURE"
+		if req.TransmissionSig == paypalSignature {
+			status = "SUCCESS"
+		}
+
+		web.Respond(context.Background(), w, paypal.VerifyWebhookResponse{VerificationStatus: status}, 200)
+	})
+
 	r := mux.NewRouter()
+	r.Handle("/v1/notifications/verify-webhook-signature", verify).Methods("POST")
 	r.Handle("/v2/checkout/orders", checkout).Methods("POST")
 	r.Handle("/v2/checkout/orders/{id}/capture", capture).Methods("POST")
+	r.Handle("/v2/payments/captures/{id}/refund", refund).Methods("POST")
 	return r
 }
 
 type mockStripe struct {
-	expectedCart []course.Course
+	expectedCart     []course.Course
+	expectedBundles  []bundle.Bundle
+	expectedCurrency string
+	expectedDiscount int
+	expectedPrice    string
+	sessions         []string
+	expired          []string
 }
 
 func (m *mockStripe) handle() http.Handler {
@@ -74,6 +129,18 @@ func (m *mockStripe) handle() http.Handler {
 		params, _ := mock.ParseParams(r)
 		lines := params["line_items"].(map[string]any)
 
+		if params["mode"] == "subscription" {
+			it := lines["0"].(map[string]any)
+			if len(lines) != 1 || it["price"] != m.expectedPrice || params["client_reference_id"] == nil {
+				web.Respond(context.Background(), w, nil, 400)
+				return
+			}
+
+			randID := fmt.Sprintf("stripe-sub-%d", rand.Intn(300))
+			web.Respond(context.Background(), w, map[string]any{"ID": randID, "URL": randID}, 201)
+			return
+		}
+
 		n := 0
 		tot := 0
 		for _, li := range lines {
@@ -85,6 +152,11 @@ func (m *mockStripe) handle() http.Handler {
 			}
 
 			pd := it["price_data"].(map[string]any)
+			if pd["currency"] != strings.ToLower(m.expectedCurrency) {
+				web.Respond(context.Background(), w, nil, 400)
+				return
+			}
+
 			s := pd["unit_amount"].(string)
 			amount, err := strconv.ParseInt(s, 10, 0)
 			if err != nil {
@@ -92,18 +164,25 @@ func (m *mockStripe) handle() http.Handler {
 				return
 			}
 
-			tot += int(amount / 100)
-			n += 1
+			tot += int(amount)
+
+			prod := pd["product_data"].(map[string]any)
+			if name, _ := prod["name"].(string); !strings.HasPrefix(name, "VAT ") {
+				n += 1
+			}
 		}
 
-		if n != len(m.expectedCart) {
+		if n != len(m.expectedCart)+len(m.expectedBundles) {
 			web.Respond(context.Background(), w, nil, 400)
 			return
 		}
 
-		exp := 0
+		exp := -m.expectedDiscount
 		for _, c := range m.expectedCart {
-			exp += c.Price
+			exp += c.Prices[m.expectedCurrency]
+		}
+		for _, b := range m.expectedBundles {
+			exp += b.Prices[m.expectedCurrency]
 		}
 
 		if tot != exp {
@@ -116,7 +195,21 @@ func (m *mockStripe) handle() http.Handler {
 		web.Respond(context.Background(), w, ord, 201)
 	})
 
+	refund := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params, _ := mock.ParseParams(r)
+		ref := map[string]any{"id": fmt.Sprintf("re-%v", params["payment_intent"]), "status": "succeeded"}
+		web.Respond(context.Background(), w, ref, 200)
+	})
+
+	expire := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		m.expired = append(m.expired, id)
+		web.Respond(context.Background(), w, map[string]any{"id": id, "status": "expired"}, 200)
+	})
+
 	r := mux.NewRouter()
 	r.Handle("/v1/checkout/sessions", checkout).Methods("POST")
+	r.Handle("/v1/checkout/sessions/{id}/expire", expire).Methods("POST")
+	r.Handle("/v1/refunds", refund).Methods("POST")
 	return r
 }
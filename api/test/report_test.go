@@ -0,0 +1,231 @@
+package test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/core/order"
+	"github.com/irsalhamdi/e-commerce-video/core/report"
+)
+
+type reportTest struct {
+	*TestEnv
+}
+
+func TestReport(t *testing.T) {
+	env, err := NewTestEnv(t, "report_test")
+	if err != nil {
+		t.Fatalf("initializing test env: %v", err)
+	}
+
+	pt := &reportTest{env}
+	ct := &courseTest{env}
+	rt := &cartTest{env}
+	ot := &orderTest{env}
+
+	c1 := ct.createCourseOK(t)
+	c2 := ct.createCourseOK(t)
+	c3 := ct.createCourseOK(t)
+
+	rt.createItemOK(t, c1.ID)
+	rt.createItemOK(t, c2.ID)
+	ot.Paypal.expectedCart = []course.Course{c1, c2}
+	ot.Paypal.expectedCurrency = "USD"
+	ot.checkoutPaypal(t, order.CheckoutNew{Currency: "USD", Country: "US"})
+
+	rt.createItemOK(t, c3.ID)
+	ot.Stripe.expectedCart = []course.Course{c3}
+	ot.Stripe.expectedCurrency = "EUR"
+	ot.checkoutStripe(t, order.CheckoutNew{Currency: "EUR", Country: "US"})
+
+	pt.reportUnauth(t)
+
+	rs := pt.revenueOK(t, "?group=course", 3)
+	for _, r := range rs {
+		var c course.Course
+		switch r.Key {
+		case c1.ID:
+			c = c1
+		case c2.ID:
+			c = c2
+		case c3.ID:
+			c = c3
+		default:
+			t.Fatalf("unexpected course %s in revenue report", r.Key)
+		}
+		if r.Label != c.Name || r.Gross != c.Prices[r.Currency] || r.Orders != 1 || r.Items != 1 {
+			t.Fatalf("wrong revenue for course %s: %+v", c.ID, r)
+		}
+	}
+
+	rs = pt.revenueOK(t, "?group=provider", 2)
+	for _, r := range rs {
+		if r.Key == order.ProviderPaypal && (r.Orders != 1 || r.Items != 2 || r.Gross != c1.Prices["USD"]+c2.Prices["USD"]) {
+			t.Fatalf("wrong paypal revenue: %+v", r)
+		}
+	}
+
+	pt.revenueOK(t, "?group=status&status=refunded", 0)
+	pt.revenueOK(t, "?group=day&from=2000-01-01&to=2000-01-31", 0)
+	pt.revenueBad(t, "?group=year")
+	pt.revenueBad(t, "?from=yesterday")
+
+	pt.revenueCSVOK(t, "?group=month&format=csv", 3)
+
+	cs := pt.conversionOK(t)
+	if len(cs) != 3 {
+		t.Fatalf("expected conversion for 2 providers and the total, got %d rows", len(cs))
+	}
+	total := cs[len(cs)-1]
+	if total.Orders != 2 || total.Succeeded != 2 || total.Rate != 1 {
+		t.Fatalf("wrong total conversion: %+v", total)
+	}
+}
+
+func (pt *reportTest) revenueOK(t *testing.T, query string, n int) []report.Revenue {
+	if err := Login(pt.Server, pt.AdminEmail, pt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(pt.Server)
+
+	r, err := http.NewRequest(http.MethodGet, pt.URL+"/reports/revenue"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := pt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't fetch revenue report: status code %s", w.Status)
+	}
+
+	var got []report.Revenue
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal revenue report: %v", err)
+	}
+
+	if len(got) != n {
+		t.Fatalf("expected %d revenue rows with query %s, got %d", n, query, len(got))
+	}
+
+	return got
+}
+
+func (pt *reportTest) revenueBad(t *testing.T, query string) {
+	if err := Login(pt.Server, pt.AdminEmail, pt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(pt.Server)
+
+	r, err := http.NewRequest(http.MethodGet, pt.URL+"/reports/revenue"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := pt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected bad request with query %s, got %s", query, w.Status)
+	}
+}
+
+func (pt *reportTest) revenueCSVOK(t *testing.T, query string, n int) {
+	if err := Login(pt.Server, pt.AdminEmail, pt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(pt.Server)
+
+	r, err := http.NewRequest(http.MethodGet, pt.URL+"/reports/revenue"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := pt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't export revenue report: status code %s", w.Status)
+	}
+
+	if ct := w.Header.Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Fatalf("expected csv content type, got %s", ct)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("cannot parse revenue csv: %v", err)
+	}
+
+	if len(records) != n {
+		t.Fatalf("expected %d csv records, got %d", n, len(records))
+	}
+
+	if records[0][0] != "key" {
+		t.Fatalf("expected csv header, got %v", records[0])
+	}
+}
+
+func (pt *reportTest) conversionOK(t *testing.T) []report.Conversion {
+	if err := Login(pt.Server, pt.AdminEmail, pt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(pt.Server)
+
+	r, err := http.NewRequest(http.MethodGet, pt.URL+"/reports/conversion", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := pt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't fetch conversion report: status code %s", w.Status)
+	}
+
+	var got []report.Conversion
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal conversion report: %v", err)
+	}
+
+	return got
+}
+
+func (pt *reportTest) reportUnauth(t *testing.T) {
+	if err := Login(pt.Server, pt.UserEmail, pt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(pt.Server)
+
+	r, err := http.NewRequest(http.MethodGet, pt.URL+"/reports/revenue", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := pt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected users to be denied reports, got %s", w.Status)
+	}
+}
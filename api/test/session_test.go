@@ -0,0 +1,65 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/database"
+)
+
+func TestSessionStore(t *testing.T) {
+	env, err := NewTestEnv(t, "session_test")
+	if err != nil {
+		t.Fatalf("initializing test env: %v", err)
+	}
+
+	ctx := context.Background()
+	store := database.NewSessionStore(env.DB)
+
+	if _, found, err := store.Find("missing"); err != nil || found {
+		t.Fatalf("expected missing session not to be found: found %t, err %v", found, err)
+	}
+
+	if err := store.Commit("live", []byte("first"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Commit("live", []byte("second"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	b, found, err := store.Find("live")
+	if err != nil || !found {
+		t.Fatalf("expected live session to be found: found %t, err %v", found, err)
+	}
+	if !bytes.Equal(b, []byte("second")) {
+		t.Fatalf("expected overwritten session data, got %q", b)
+	}
+
+	if err := store.Commit("old", []byte("old"), time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := store.Find("old"); err != nil || found {
+		t.Fatalf("expected expired session not to be found: found %t, err %v", found, err)
+	}
+
+	if err := store.DeleteExpired(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	if err := env.DB.Get(&n, "SELECT COUNT(*) FROM sessions"); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 session after cleanup, got %d", n)
+	}
+
+	if err := store.Delete("live"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, err := store.Find("live"); err != nil || found {
+		t.Fatalf("expected deleted session not to be found: found %t, err %v", found, err)
+	}
+}
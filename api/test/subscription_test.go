@@ -0,0 +1,197 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/core/subscription"
+	"github.com/irsalhamdi/e-commerce-video/core/user"
+)
+
+type subscriptionTest struct {
+	*TestEnv
+}
+
+func TestSubscription(t *testing.T) {
+	env, err := NewTestEnv(t, "subscription_test")
+	if err != nil {
+		t.Fatalf("initializing test env: %v", err)
+	}
+
+	st := &subscriptionTest{env}
+	ct := &courseTest{env}
+	vt := &videoTest{env}
+	ot := &orderTest{env}
+
+	c := ct.createCourseOK(t)
+	v := vt.createVideoWithFreeOK(t, c.ID, 0, false)
+
+	st.showFullVideo(t, v.ID, http.StatusForbidden)
+	st.showCurrent(t, http.StatusNotFound)
+
+	ot.Stripe.expectedPrice = "price_monthly"
+	st.subscribe(t, subscription.Yearly, http.StatusUnprocessableEntity)
+	st.subscribe(t, subscription.Monthly, http.StatusOK)
+
+	userID := st.currentUserID(t)
+	end := time.Now().Add(30 * 24 * time.Hour).Unix()
+
+	sub := map[string]any{
+		"id":                 "sub_test",
+		"object":             "subscription",
+		"status":             "active",
+		"current_period_end": end,
+		"metadata":           map[string]string{"user_id": userID},
+		"items": map[string]any{
+			"object": "list",
+			"data":   []map[string]any{{"price": map[string]any{"recurring": map[string]any{"interval": "month"}}}},
+		},
+	}
+
+	ot.sendStripeEvent(t, "evt_sub_created", "customer.subscription.created", sub)
+	ot.sendStripeEvent(t, "evt_sub_created", "customer.subscription.created", sub)
+
+	st.showFullVideo(t, v.ID, http.StatusOK)
+	st.showCurrent(t, http.StatusOK)
+	st.subscribe(t, subscription.Monthly, http.StatusUnprocessableEntity)
+
+	ot.sendStripeEvent(t, "evt_inv_failed", "invoice.payment_failed", map[string]any{
+		"id":           "in_failed",
+		"subscription": "sub_test",
+	})
+	st.showFullVideo(t, v.ID, http.StatusForbidden)
+
+	ot.sendStripeEvent(t, "evt_inv_paid", "invoice.paid", map[string]any{
+		"id":           "in_paid",
+		"subscription": "sub_test",
+		"lines": map[string]any{
+			"object": "list",
+			"data":   []map[string]any{{"period": map[string]any{"end": end}}},
+		},
+	})
+	st.showFullVideo(t, v.ID, http.StatusOK)
+
+	sub["status"] = "canceled"
+	ot.sendStripeEvent(t, "evt_sub_deleted", "customer.subscription.deleted", sub)
+	st.showFullVideo(t, v.ID, http.StatusForbidden)
+
+	sub["status"] = "active"
+	ot.sendStripeEvent(t, "evt_sub_late", "customer.subscription.updated", sub)
+	st.showFullVideo(t, v.ID, http.StatusForbidden)
+}
+
+func (st *subscriptionTest) subscribe(t *testing.T, plan subscription.Plan, status int) {
+	if err := Login(st.Server, st.UserEmail, st.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(st.Server)
+
+	body, err := json.Marshal(subscription.SubscriptionNew{Plan: plan})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, st.URL+"/subscriptions", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := st.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d subscribing to %s plan, got %s", status, plan, w.Status)
+	}
+}
+
+func (st *subscriptionTest) showCurrent(t *testing.T, status int) {
+	if err := Login(st.Server, st.UserEmail, st.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(st.Server)
+
+	r, err := http.NewRequest(http.MethodGet, st.URL+"/subscriptions/current", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := st.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d showing subscription, got %s", status, w.Status)
+	}
+
+	if status != http.StatusOK {
+		return
+	}
+
+	var got subscription.Subscription
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal subscription: %v", err)
+	}
+
+	if got.Plan != subscription.Monthly || got.Status != subscription.Active {
+		t.Fatalf("wrong subscription payload: %+v", got)
+	}
+}
+
+func (st *subscriptionTest) showFullVideo(t *testing.T, id string, status int) {
+	if err := Login(st.Server, st.UserEmail, st.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(st.Server)
+
+	r, err := http.NewRequest(http.MethodGet, st.URL+"/videos/"+id+"/full", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := st.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d showing full video, got %s", status, w.Status)
+	}
+}
+
+func (st *subscriptionTest) currentUserID(t *testing.T) string {
+	if err := Login(st.Server, st.UserEmail, st.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(st.Server)
+
+	r, err := http.NewRequest(http.MethodGet, st.URL+"/users/current", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := st.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't fetch current user: status code %s", w.Status)
+	}
+
+	var got user.User
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal current user: %v", err)
+	}
+
+	return got.ID
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"net/http/cookiejar"
 	"testing"
 
 	"github.com/irsalhamdi/e-commerce-video/core/token"
@@ -28,6 +29,9 @@ func TestToken(t *testing.T) {
 
 	ru := tt.signupTestUser(t, "mary.lu@recovery.com")
 	tt.recoveryToken(t, ru)
+
+	lu := tt.signupTestUser(t, "mary.lu@login.com")
+	tt.loginToken(t, lu)
 }
 
 func (tt *tokenTest) signupTestUser(t *testing.T, email string) user.UserSignup {
@@ -183,6 +187,28 @@ func (tt *tokenTest) recoveryToken(t *testing.T, u user.UserSignup) {
 		t.Fatal("user should have the old password")
 	}
 
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := &http.Client{Transport: tt.Client().Transport, Jar: jar}
+
+	r, err = http.NewRequest(http.MethodPost, tt.URL+"/auth/login", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetBasicAuth(u.Email, u.Password)
+
+	w, err = old.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Body.Close()
+
+	if w.StatusCode != http.StatusNoContent {
+		t.Fatalf("can't login with the old password: status code %s", w.Status)
+	}
+
 	body, err = json.Marshal(&struct {
 		Token           string `json:"token"`
 		Password        string `json:"password"`
@@ -211,7 +237,67 @@ func (tt *tokenTest) recoveryToken(t *testing.T, u user.UserSignup) {
 		t.Fatalf("second token should have been valid")
 	}
 
+	w, err = old.Get(tt.URL + "/users/current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Body.Close()
+
+	if w.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("sessions should have been revoked by the password change: status code %s", w.Status)
+	}
+
 	if err := Login(tt.Server, u.Email, newPassword); err != nil {
 		t.Fatal("user should have the new password at this point")
 	}
 }
+
+func (tt *tokenTest) loginToken(t *testing.T, u user.UserSignup) {
+	if err := Activate(tt.Server, u.Email, tt.Mailer); err != nil {
+		t.Fatal(err)
+	}
+	Logout(tt.Server)
+
+	tok := tt.requestToken(t, u.Email, token.LoginToken)
+
+	tt.redeemLogin(t, tok, http.StatusNoContent)
+
+	w, err := tt.Client().Get(tt.URL + "/users/current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("user should be logged in by the login token: status code %s", w.Status)
+	}
+	Logout(tt.Server)
+
+	tt.redeemLogin(t, tok, http.StatusBadRequest)
+}
+
+func (tt *tokenTest) redeemLogin(t *testing.T, tok string, status int) {
+	body, err := json.Marshal(&struct {
+		Token string `json:"token"`
+	}{
+		Token: tok,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, tt.URL+"/tokens/login", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := tt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status %d redeeming login token, got %s", status, w.Status)
+	}
+}
@@ -0,0 +1,176 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/core/auth"
+	"github.com/irsalhamdi/e-commerce-video/totp"
+)
+
+type totpTest struct {
+	*TestEnv
+}
+
+func TestTOTP(t *testing.T) {
+	env, err := NewTestEnv(t, "totp_test")
+	if err != nil {
+		t.Fatalf("initializing test env: %v", err)
+	}
+
+	tt := &totpTest{env}
+
+	if err := Login(tt.Server, tt.UserEmail, tt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+
+	enr := tt.enrollOK(t)
+	step := totp.Step(time.Now())
+	codes := tt.confirmOK(t, enr.Secret, step)
+	Logout(tt.Server)
+
+	tt.loginPending(t)
+	tt.currentUser(t, http.StatusUnauthorized)
+	tt.verify(t, code(t, enr.Secret, step), http.StatusUnauthorized)
+	tt.verify(t, code(t, enr.Secret, step+1), http.StatusNoContent)
+	tt.currentUser(t, http.StatusOK)
+	Logout(tt.Server)
+
+	tt.loginPending(t)
+	tt.verify(t, codes[0], http.StatusNoContent)
+	Logout(tt.Server)
+
+	tt.loginPending(t)
+	tt.verify(t, codes[0], http.StatusUnauthorized)
+	tt.verify(t, codes[1], http.StatusNoContent)
+	tt.send(t, http.MethodDelete, "/auth/totp", codes[2], http.StatusNoContent)
+	Logout(tt.Server)
+
+	if err := Login(tt.Server, tt.UserEmail, tt.UserPass); err != nil {
+		t.Fatalf("login without two-factor authentication should succeed: %v", err)
+	}
+	Logout(tt.Server)
+}
+
+func code(t *testing.T, secret string, step int64) string {
+	c, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func (tt *totpTest) enrollOK(t *testing.T) auth.TOTPEnrollment {
+	r, err := http.NewRequest(http.MethodPost, tt.URL+"/auth/totp", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := tt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusCreated {
+		t.Fatalf("can't enroll two-factor authentication: status code %s", w.Status)
+	}
+
+	var enr auth.TOTPEnrollment
+	if err := json.NewDecoder(w.Body).Decode(&enr); err != nil {
+		t.Fatalf("cannot unmarshal enrollment: %v", err)
+	}
+
+	if enr.Secret == "" || enr.URI != totp.URI("Govod", tt.UserEmail, enr.Secret) {
+		t.Fatalf("wrong enrollment payload: %+v", enr)
+	}
+
+	return enr
+}
+
+func (tt *totpTest) confirmOK(t *testing.T, secret string, step int64) []string {
+	w := tt.send(t, http.MethodPost, "/auth/totp/confirm", code(t, secret, step), http.StatusOK)
+	defer w.Body.Close()
+
+	var rec auth.TOTPRecovery
+	if err := json.NewDecoder(w.Body).Decode(&rec); err != nil {
+		t.Fatalf("cannot unmarshal recovery codes: %v", err)
+	}
+
+	if len(rec.Codes) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %d", len(rec.Codes))
+	}
+
+	return rec.Codes
+}
+
+func (tt *totpTest) loginPending(t *testing.T) {
+	r, err := http.NewRequest(http.MethodPost, tt.URL+"/auth/login", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetBasicAuth(tt.UserEmail, tt.UserPass)
+
+	w, err := tt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusAccepted {
+		t.Fatalf("login should wait for the two-factor code: status code %s", w.Status)
+	}
+
+	var got auth.LoginPending
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal pending login: %v", err)
+	}
+
+	if !got.TOTPRequired {
+		t.Fatal("pending login should require the two-factor code")
+	}
+}
+
+func (tt *totpTest) verify(t *testing.T, code string, status int) {
+	w := tt.send(t, http.MethodPost, "/auth/totp/verify", code, status)
+	w.Body.Close()
+}
+
+func (tt *totpTest) send(t *testing.T, method string, path string, code string, status int) *http.Response {
+	body, err := json.Marshal(auth.TOTPCode{Code: code})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(method, tt.URL+path, bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := tt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.StatusCode != status {
+		w.Body.Close()
+		t.Fatalf("expected status %d on %s %s, got %s", status, method, path, w.Status)
+	}
+
+	return w
+}
+
+func (tt *totpTest) currentUser(t *testing.T, status int) {
+	w, err := tt.Client().Get(tt.URL + "/users/current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status %d showing current user, got %s", status, w.Status)
+	}
+}
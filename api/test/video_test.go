@@ -44,6 +44,10 @@ func TestVideo(t *testing.T) {
 }
 
 func (vt *videoTest) createVideoOK(t *testing.T, course string, index int) video.Video {
+	return vt.createVideoWithFreeOK(t, course, index, true)
+}
+
+func (vt *videoTest) createVideoWithFreeOK(t *testing.T, course string, index int, free bool) video.Video {
 	if err := Login(vt.Server, vt.AdminEmail, vt.AdminPass); err != nil {
 		t.Fatal(err)
 	}
@@ -54,7 +58,7 @@ func (vt *videoTest) createVideoOK(t *testing.T, course string, index int) video
 		Index:       index,
 		Name:        "Video Test" + strconv.Itoa(rand.Intn(1000)),
 		Description: "This is a test video",
-		Free:        true,
+		Free:        free,
 		URL:         "",
 		ImageURL:    "/images/new.png",
 	}
@@ -0,0 +1,197 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/irsalhamdi/e-commerce-video/core/cart"
+	"github.com/irsalhamdi/e-commerce-video/core/wishlist"
+	"github.com/irsalhamdi/e-commerce-video/validate"
+)
+
+type wishlistTest struct {
+	*TestEnv
+}
+
+func TestWishlist(t *testing.T) {
+	env, err := NewTestEnv(t, "wishlist_test")
+	if err != nil {
+		t.Fatalf("initializing test env: %v", err)
+	}
+
+	wt := &wishlistTest{env}
+	ct := &courseTest{env}
+	rt := &cartTest{env}
+
+	c1 := ct.createCourseOK(t)
+	c2 := ct.createCourseOK(t)
+
+	wt.listWishlistOK(t, 0)
+
+	wt.addItem(t, validate.GenerateID(), http.StatusNotFound)
+	wt.addItem(t, c1.ID, http.StatusCreated)
+	wt.addItem(t, c1.ID, http.StatusCreated)
+	wt.addItem(t, c2.ID, http.StatusCreated)
+	wt.listWishlistOK(t, 2)
+
+	wt.listPopularOK(t, c1.ID, 1)
+
+	wt.deleteItemOK(t, c2.ID)
+	wt.listWishlistOK(t, 1)
+
+	wt.moveToCart(t, c2.ID, http.StatusNotFound)
+	item := wt.moveToCart(t, c1.ID, http.StatusCreated)
+	wt.listWishlistOK(t, 0)
+	rt.showCartOK(t, cart.Cart{Items: []cart.Item{item}, Bundles: []cart.Bundle{}})
+}
+
+func (wt *wishlistTest) addItem(t *testing.T, courseID string, status int) {
+	if err := Login(wt.Server, wt.UserEmail, wt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(wt.Server)
+
+	body, err := json.Marshal(wishlist.ItemNew{CourseID: courseID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := http.NewRequest(http.MethodPut, wt.URL+"/wishlist/items", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := wt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d adding course %s to the wishlist, got %s", status, courseID, w.Status)
+	}
+}
+
+func (wt *wishlistTest) deleteItemOK(t *testing.T, courseID string) {
+	if err := Login(wt.Server, wt.UserEmail, wt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(wt.Server)
+
+	r, err := http.NewRequest(http.MethodDelete, wt.URL+"/wishlist/items/"+courseID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := wt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusNoContent {
+		t.Fatalf("can't delete wishlist item: status code %s", w.Status)
+	}
+}
+
+func (wt *wishlistTest) listWishlistOK(t *testing.T, n int) {
+	if err := Login(wt.Server, wt.UserEmail, wt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(wt.Server)
+
+	r, err := http.NewRequest(http.MethodGet, wt.URL+"/wishlist", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := wt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't list wishlist: status code %s", w.Status)
+	}
+
+	var got []wishlist.Item
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal wishlist: %v", err)
+	}
+
+	if len(got) != n {
+		t.Fatalf("expected %d wishlist items, got %d", n, len(got))
+	}
+}
+
+func (wt *wishlistTest) moveToCart(t *testing.T, courseID string, status int) cart.Item {
+	if err := Login(wt.Server, wt.UserEmail, wt.UserPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(wt.Server)
+
+	r, err := http.NewRequest(http.MethodPost, wt.URL+"/wishlist/items/"+courseID+"/cart", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := wt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != status {
+		t.Fatalf("expected status code %d moving course %s to the cart, got %s", status, courseID, w.Status)
+	}
+
+	var got cart.Item
+	if status == http.StatusCreated {
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("cannot unmarshal cart item: %v", err)
+		}
+	}
+
+	return got
+}
+
+func (wt *wishlistTest) listPopularOK(t *testing.T, courseID string, count int) {
+	if err := Login(wt.Server, wt.AdminEmail, wt.AdminPass); err != nil {
+		t.Fatal(err)
+	}
+	defer Logout(wt.Server)
+
+	r, err := http.NewRequest(http.MethodGet, wt.URL+"/wishlist/popular?limit=5", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := wt.Client().Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Body.Close()
+
+	if w.StatusCode != http.StatusOK {
+		t.Fatalf("can't list most wishlisted courses: status code %s", w.Status)
+	}
+
+	var got []wishlist.Popular
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot unmarshal most wishlisted courses: %v", err)
+	}
+
+	for _, p := range got {
+		if p.CourseID == courseID {
+			if p.Count != count {
+				t.Fatalf("expected course %s to be wishlisted %d times, got %d", courseID, count, p.Count)
+			}
+			return
+		}
+	}
+
+	t.Fatalf("course %s missing from the most wishlisted", courseID)
+}
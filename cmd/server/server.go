@@ -8,7 +8,6 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/ardanlabs/conf/v3"
@@ -16,8 +15,11 @@ import (
 	"github.com/irsalhamdi/e-commerce-video/api/background"
 	"github.com/irsalhamdi/e-commerce-video/config"
 	"github.com/irsalhamdi/e-commerce-video/core/auth"
+	"github.com/irsalhamdi/e-commerce-video/core/cart"
+	"github.com/irsalhamdi/e-commerce-video/core/order"
 	"github.com/irsalhamdi/e-commerce-video/database"
 	"github.com/irsalhamdi/e-commerce-video/email"
+	"github.com/irsalhamdi/e-commerce-video/tax"
 	"github.com/plutov/paypal/v4"
 	"github.com/sirupsen/logrus"
 	stripecl "github.com/stripe/stripe-go/v74/client"
@@ -43,6 +45,11 @@ func Run(logger *logrus.Logger) error {
 		return fmt.Errorf("parsing config: %w", err)
 	}
 
+	rates, err := tax.MakeRates(cfg.Tax.Rates)
+	if err != nil {
+		return fmt.Errorf("parsing tax rates: %w", err)
+	}
+
 	lw := logger.Writer()
 	defer lw.Close()
 	errLog := log.New(lw, "", 0)
@@ -52,16 +59,52 @@ func Run(logger *logrus.Logger) error {
 		return fmt.Errorf("failed to open db connection: %w", err)
 	}
 
+	bg := background.New(logger)
+
 	sessionManager := scs.New()
-	sessionManager.Lifetime = 24 * time.Hour
+	sessionManager.Lifetime = cfg.Session.Lifetime
+
+	var store *database.SessionStore
+	switch cfg.Session.Store {
+	case "postgres":
+		store = database.NewSessionStore(db)
+		sessionManager.Store = store
+	case "memory":
+	default:
+		return fmt.Errorf("unknown session store %q", cfg.Session.Store)
+	}
+
+	bg.Every(cfg.Session.CleanupInterval, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Session.CleanupInterval)
+		defer cancel()
+		if store != nil {
+			if err := store.DeleteExpired(ctx); err != nil {
+				return err
+			}
+		}
+		return auth.DeleteExpiredSessions(ctx, db)
+	})
 
 	links := email.Links{
 		ActivationURL: cfg.Email.ActivationURL,
+		GiftURL:       cfg.Email.GiftURL,
+		CartURL:       cfg.Email.CartURL,
 		RecoveryURL:   cfg.Email.RecoveryURL,
+		LoginURL:      cfg.Email.LoginURL,
 	}
 	mail := email.New(cfg.Email.Address, cfg.Email.Password, cfg.Email.Host, cfg.Email.Port, links)
 
-	bg := background.New(logger)
+	bg.Every(cfg.Order.SweepInterval, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Order.SweepInterval)
+		defer cancel()
+		return order.ExpireStale(ctx, db, cfg.Order.PendingTTL)
+	})
+
+	bg.Every(cfg.Cart.ReminderInterval, func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Cart.ReminderInterval)
+		defer cancel()
+		return cart.RemindAbandoned(ctx, db, mail, cfg.Cart.ReminderAfter)
+	})
 
 	pp, err := paypal.NewClient(
 		cfg.Paypal.ClientID,
@@ -78,31 +121,61 @@ func Run(logger *logrus.Logger) error {
 
 	strp := &stripecl.API{}
 	strp.Init(cfg.Stripe.APISecret, nil)
+	stripeProv := order.NewStripe(strp, cfg.Stripe)
 
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Oauth.DiscoveryTimeout)
 	defer cancel()
-	google := cfg.Oauth.Google
-	oauthProvs, err := auth.MakeProviders(ctx, []auth.ProviderConfig{
-		{Name: "google", Client: google.Client, Secret: google.Secret, URL: google.URL, RedirectURL: google.RedirectURL},
-	})
+	oauthCfg, err := config.OauthProviders(prefix, cfg.Oauth)
+	if err != nil {
+		return fmt.Errorf("parsing oauth providers: %w", err)
+	}
+
+	provCfg := make([]auth.ProviderConfig, len(oauthCfg))
+	for i, p := range oauthCfg {
+		provCfg[i] = auth.ProviderConfig{
+			Name:        p.Name,
+			Client:      p.Client,
+			Secret:      p.Secret,
+			URL:         p.URL,
+			AuthURL:     p.AuthURL,
+			TokenURL:    p.TokenURL,
+			UserInfoURL: p.UserInfoURL,
+			EmailsURL:   p.EmailsURL,
+			RedirectURL: p.RedirectURL,
+			Scopes:      p.Scopes,
+			Claims: auth.ClaimMapping{
+				Name:             p.NameClaim,
+				Email:            p.EmailClaim,
+				Verified:         p.VerifiedClaim,
+				SkipVerification: p.SkipEmailVerification,
+			},
+		}
+	}
+
+	oauthProvs, err := auth.MakeProviders(ctx, provCfg)
 	if err != nil {
 		return fmt.Errorf("failed to discover oauth providers: %w", err)
 	}
 
 	mux := api.APIMux(api.APIConfig{
-		CorsOrigin:         cfg.Cors.Origin,
-		Log:                logger,
-		DB:                 db,
-		Session:            sessionManager,
-		Mailer:             mail,
-		TokenTimeout:       cfg.Email.TokenTimeout,
-		Background:         bg,
-		Paypal:             pp,
-		Stripe:             strp,
-		StripeCfg:          cfg.Stripe,
+		CorsOrigin:   cfg.Cors.Origin,
+		Log:          logger,
+		DB:           db,
+		Session:      sessionManager,
+		Mailer:       mail,
+		TokenTimeout: cfg.Email.TokenTimeout,
+		Background:   bg,
+		Payments: map[string]order.PaymentProvider{
+			order.ProviderPaypal: order.NewPaypal(pp, cfg.Paypal.WebhookID),
+			order.ProviderStripe: stripeProv,
+		},
+		Subscriptions:      stripeProv,
+		TaxRates:           rates,
 		Providers:          oauthProvs,
 		LoginRedirectURL:   cfg.Oauth.LoginRedirectURL,
 		ActivationRequired: cfg.Auth.ActivationRequired,
+		AdminTOTPRequired:  cfg.Auth.AdminTOTPRequired,
+		UserCacheTTL:       cfg.Auth.UserCacheTTL,
 	})
 
 	api := http.Server{
@@ -5,14 +5,18 @@ import (
 )
 
 type Config struct {
-	Cors   Cors
-	Web    Web
-	DB     DB
-	Email  Email
-	Paypal Paypal
-	Stripe Stripe
-	Oauth  Oauth
-	Auth   Auth
+	Cors    Cors
+	Web     Web
+	DB      DB
+	Session Session
+	Email   Email
+	Paypal  Paypal
+	Stripe  Stripe
+	Order   Order
+	Cart    Cart
+	Tax     Tax
+	Oauth   Oauth
+	Auth    Auth
 }
 
 type Cors struct {
@@ -37,40 +41,64 @@ type DB struct {
 	DisableTLS   bool   `conf:"default:true"`
 }
 
+type Session struct {
+	Store           string        `conf:"default:postgres,help:postgres or memory"`
+	Lifetime        time.Duration `conf:"default:24h"`
+	CleanupInterval time.Duration `conf:"default:5m"`
+}
+
 type Email struct {
 	Host          string
 	Port          string
 	Address       string
 	Password      string
 	RecoveryURL   string        `conf:"default:http://mylocal.com:3000/password/confirm?token="`
+	LoginURL      string        `conf:"default:http://mylocal.com:3000/login/confirm?token="`
 	ActivationURL string        `conf:"default:http://mylocal.com:3000/activate/confirm?token="`
+	GiftURL       string        `conf:"default:http://mylocal.com:3000/gift/claim?token="`
+	CartURL       string        `conf:"default:http://mylocal.com:3000/cart"`
 	TokenTimeout  time.Duration `conf:"default:10s"`
 }
 
+type Tax struct {
+	Rates map[string]int
+}
+
 type Stripe struct {
-	APISecret     string
-	WebhookSecret string
-	SuccessURL    string `conf:"default:http://mylocal.com:3000/dashboard"`
-	CancelURL     string `conf:"default:http://mylocal.com:3000/cart"`
+	APISecret      string
+	WebhookSecret  string
+	MonthlyPriceID string
+	YearlyPriceID  string
+	SuccessURL     string `conf:"default:http://mylocal.com:3000/dashboard"`
+	CancelURL      string `conf:"default:http://mylocal.com:3000/cart"`
 }
 
 type Paypal struct {
-	ClientID string
-	Secret   string
-	URL      string `conf:"default:https://api.sandbox.paypal.com"`
+	ClientID  string
+	Secret    string
+	WebhookID string
+	URL       string `conf:"default:https://api.sandbox.paypal.com"`
+}
+
+type Order struct {
+	PendingTTL    time.Duration `conf:"default:48h"`
+	SweepInterval time.Duration `conf:"default:15m"`
+}
+
+type Cart struct {
+	ReminderAfter    time.Duration `conf:"default:24h"`
+	ReminderInterval time.Duration `conf:"default:1h"`
 }
 
 type Oauth struct {
 	DiscoveryTimeout time.Duration `conf:"default:30s"`
 	LoginRedirectURL string        `conf:"default:http://mylocal.com:3000/dashboard"`
-	Google           struct {
-		Client      string
-		Secret      string
-		URL         string `conf:"default:https://accounts.google.com"`
-		RedirectURL string `conf:"default:http://mylocal.com:8000/auth/oauth-callback/google"`
-	}
+	CallbackURL      string        `conf:"default:http://mylocal.com:8000/auth/oauth-callback/"`
+	Providers        []string      `conf:"default:google"`
 }
 
 type Auth struct {
-	ActivationRequired bool `conf:"default:false"`
+	ActivationRequired bool          `conf:"default:false"`
+	AdminTOTPRequired  bool          `conf:"default:false"`
+	UserCacheTTL       time.Duration `conf:"default:30s"`
 }
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// OauthProvider is the configuration of a single oauth provider, read from
+// the environment variables <PREFIX>_OAUTH_<NAME>_<FIELD>. Providers with an
+// issuer URL are discovered through OIDC, the others need their auth, token
+// and user info URLs. Emails must be verified by the provider unless
+// SkipEmailVerification is set.
+type OauthProvider struct {
+	Name          string
+	Client        string
+	Secret        string
+	URL           string
+	AuthURL       string
+	TokenURL      string
+	UserInfoURL   string
+	EmailsURL     string
+	RedirectURL   string
+	Scopes        []string
+	NameClaim     []string
+	EmailClaim    []string
+	VerifiedClaim string
+
+	SkipEmailVerification bool
+}
+
+// oauthPresets hold the defaults of well known providers, so that only their
+// client and secret need to be set.
+var oauthPresets = map[string]OauthProvider{
+	"google": {
+		URL:           "https://accounts.google.com",
+		VerifiedClaim: "email_verified",
+	},
+	"gitlab": {
+		URL:           "https://gitlab.com",
+		VerifiedClaim: "email_verified",
+	},
+	"github": {
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		EmailsURL:   "https://api.github.com/user/emails",
+		Scopes:      []string{"read:user", "user:email"},
+		NameClaim:   []string{"name", "login"},
+	},
+	// Microsoft needs a tenant specific URL and the xms_edov optional claim,
+	// as it doesn't send email_verified.
+	"microsoft": {
+		NameClaim:     []string{"name", "preferred_username"},
+		VerifiedClaim: "xms_edov",
+	},
+}
+
+// OauthProviders reads the configuration of the providers listed in
+// Oauth.Providers.
+func OauthProviders(prefix string, cfg Oauth) ([]OauthProvider, error) {
+	provs := make([]OauthProvider, 0, len(cfg.Providers))
+
+	for _, name := range cfg.Providers {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		env := func(field string) (string, bool) {
+			return os.LookupEnv(fmt.Sprintf("%s_OAUTH_%s_%s", prefix, strings.ToUpper(name), field))
+		}
+		str := func(dst *string, field string) {
+			if v, ok := env(field); ok {
+				*dst = v
+			}
+		}
+		list := func(dst *[]string, field string) {
+			if v, ok := env(field); ok {
+				*dst = strings.Split(v, ";")
+			}
+		}
+
+		p := oauthPresets[name]
+		p.Name = name
+		p.RedirectURL = cfg.CallbackURL + name
+
+		str(&p.Client, "CLIENT")
+		str(&p.Secret, "SECRET")
+		str(&p.URL, "URL")
+		str(&p.AuthURL, "AUTH_URL")
+		str(&p.TokenURL, "TOKEN_URL")
+		str(&p.UserInfoURL, "USERINFO_URL")
+		str(&p.EmailsURL, "EMAILS_URL")
+		str(&p.RedirectURL, "REDIRECT_URL")
+		list(&p.Scopes, "SCOPES")
+		list(&p.NameClaim, "NAME_CLAIM")
+		list(&p.EmailClaim, "EMAIL_CLAIM")
+		str(&p.VerifiedClaim, "VERIFIED_CLAIM")
+
+		if v, ok := env("SKIP_EMAIL_VERIFICATION"); ok {
+			skip, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("oauth provider %s: parsing skip email verification: %w", name, err)
+			}
+			p.SkipEmailVerification = skip
+		}
+
+		if p.Client == "" {
+			return nil, fmt.Errorf("oauth provider %s: client not set", name)
+		}
+		if p.URL == "" && p.AuthURL == "" {
+			return nil, fmt.Errorf("oauth provider %s: neither issuer nor auth url set", name)
+		}
+
+		provs = append(provs, p)
+	}
+
+	return provs, nil
+}
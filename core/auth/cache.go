@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/core/user"
+	"github.com/jmoiron/sqlx"
+)
+
+// UserCache keeps the logged users for a short time, so that they are not
+// fetched on every request while changes to their role, active flag or
+// two-factor settings still take effect quickly.
+type UserCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]cachedUser
+}
+
+type cachedUser struct {
+	user    user.User
+	expires time.Time
+}
+
+func NewUserCache(ttl time.Duration) *UserCache {
+	return &UserCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedUser),
+	}
+}
+
+// Fetch returns the current state of the user.
+func (c *UserCache) Fetch(ctx context.Context, db sqlx.ExtContext, userID string) (user.User, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[userID]
+	c.mu.Unlock()
+
+	if ok && now.Before(e.expires) {
+		return e.user, nil
+	}
+
+	u, err := user.Fetch(ctx, db, userID)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, id)
+		}
+	}
+	c.entries[userID] = cachedUser{user: u, expires: now.Add(c.ttl)}
+
+	return u, nil
+}
@@ -5,10 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
-	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/irsalhamdi/e-commerce-video/api/web"
 	"github.com/irsalhamdi/e-commerce-video/api/weberr"
 	"github.com/irsalhamdi/e-commerce-video/core/claims"
@@ -44,10 +44,15 @@ func HandleLogin(db *sqlx.DB, session *scs.SessionManager) web.Handler {
 			return weberr.NewError(err, err.Error(), http.StatusLocked)
 		}
 
-		if err := SaveUserSession(ctx, session, u.ID, u.Role); err != nil {
+		pending, err := Login(ctx, db, session, r, u)
+		if err != nil {
 			return fmt.Errorf("store user[%s] in session: %w", u.ID, err)
 		}
 
+		if pending {
+			return web.Respond(ctx, w, LoginPending{TOTPRequired: true}, http.StatusAccepted)
+		}
+
 		return web.Respond(ctx, w, nil, http.StatusNoContent)
 	}
 }
@@ -95,27 +100,16 @@ func HandleOauthCallback(db *sqlx.DB, session *scs.SessionManager, provs map[str
 			return weberr.NotAuthorized(err)
 		}
 
-		rawIDTok, ok := tok.Extra("id_token").(string)
+		info, err := prov.UserInfo(ctx, tok)
 		if err != nil {
-			return weberr.NotAuthorized(errors.New("id token not present"))
-		}
-
-		verifier := prov.Verifier(&oidc.Config{ClientID: prov.ClientID})
-		idTok, err := verifier.Verify(ctx, rawIDTok)
-		if err != nil {
-			return weberr.NotAuthorized(errors.New("id token not valid"))
-		}
-
-		info := UserInfo{}
-		if err := idTok.Claims(&info); err != nil {
-			return fmt.Errorf("extracting info from oauth claims: %w", err)
-		}
-
-		if info.Name == "" || info.Email == "" {
-			return fmt.Errorf("name or email not found in idToken claims: %+v", info)
+			return weberr.NotAuthorized(fmt.Errorf("fetching user info from provider %s: %w", p, err))
 		}
 
 		u, err := user.FetchByEmail(ctx, db, info.Email)
+		if err == nil && !info.Verified {
+			err := fmt.Errorf("provider %s did not verify the email of user[%s]", p, u.ID)
+			return weberr.NewError(err, "email already registered", http.StatusConflict)
+		}
 		if err != nil {
 			if !errors.Is(err, database.ErrDBNotFound) {
 				return fmt.Errorf("fetching user by email %s: %w", info.Email, err)
@@ -128,14 +122,15 @@ func HandleOauthCallback(db *sqlx.DB, session *scs.SessionManager, provs map[str
 			}
 
 			u = user.User{
-				ID:           validate.GenerateID(),
-				Name:         info.Name,
-				Email:        info.Email,
-				Role:         claims.RoleUser,
-				PasswordHash: []byte(pass),
-				CreatedAt:    now,
-				UpdatedAt:    now,
-				Active:       true,
+				ID:            validate.GenerateID(),
+				Name:          info.Name,
+				Email:         info.Email,
+				Role:          claims.RoleUser,
+				PasswordHash:  []byte(pass),
+				CreatedAt:     now,
+				UpdatedAt:     now,
+				Active:        true,
+				CartReminders: true,
 			}
 
 			if err := user.Create(ctx, db, u); err != nil {
@@ -143,17 +138,97 @@ func HandleOauthCallback(db *sqlx.DB, session *scs.SessionManager, provs map[str
 			}
 		}
 
-		if err := SaveUserSession(ctx, session, u.ID, u.Role); err != nil {
+		pending, err := Login(ctx, db, session, r, u)
+		if err != nil {
 			return fmt.Errorf("store user[%s] in session: %w", u.ID, err)
 		}
 
-		http.Redirect(w, r, redirect, http.StatusFound)
+		dst := redirect
+		if pending {
+			dst = withQuery(redirect, "totp", "required")
+		}
+
+		http.Redirect(w, r, dst, http.StatusFound)
 		return nil
 	}
 }
 
-func HandleLogout(session *scs.SessionManager) web.Handler {
+func HandleLogout(db *sqlx.DB, session *scs.SessionManager) web.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		if uid, ok := SessionUser(ctx, session); ok && SessionID(ctx, session) != "" {
+			if err := DeleteSession(ctx, db, SessionID(ctx, session), uid); err != nil {
+				return err
+			}
+		}
+
+		if err := session.Destroy(ctx); err != nil {
+			return fmt.Errorf("destroying session: %w", err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+func HandleListSessions(db *sqlx.DB, session *scs.SessionManager) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		sessions, err := FetchSessions(ctx, db, clm.UserID)
+		if err != nil {
+			return err
+		}
+
+		current := SessionID(ctx, session)
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == current
+		}
+
+		return web.Respond(ctx, w, sessions, http.StatusOK)
+	}
+}
+
+func HandleDeleteSession(db *sqlx.DB, session *scs.SessionManager) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		id := web.Param(r, "id")
+
+		if err := validate.CheckID(id); err != nil {
+			return weberr.BadRequest(fmt.Errorf("passed id is not valid: %w", err))
+		}
+
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		if err := DeleteSession(ctx, db, id, clm.UserID); err != nil {
+			return err
+		}
+
+		if id == SessionID(ctx, session) {
+			if err := session.Destroy(ctx); err != nil {
+				return fmt.Errorf("destroying session: %w", err)
+			}
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+// HandleDeleteSessions logs the user out everywhere, current session included.
+func HandleDeleteSessions(db *sqlx.DB, session *scs.SessionManager) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		if err := DeleteSessionsByUser(ctx, db, clm.UserID, ""); err != nil {
+			return err
+		}
+
 		if err := session.Destroy(ctx); err != nil {
 			return fmt.Errorf("destroying session: %w", err)
 		}
@@ -181,14 +256,15 @@ func HandleSignup(db *sqlx.DB, session *scs.SessionManager, activationRequired b
 		now := time.Now().UTC()
 
 		usr := user.User{
-			ID:           validate.GenerateID(),
-			Name:         u.Name,
-			Email:        u.Email,
-			Role:         claims.RoleUser,
-			PasswordHash: hash,
-			CreatedAt:    now,
-			UpdatedAt:    now,
-			Active:       !activationRequired,
+			ID:            validate.GenerateID(),
+			Name:          u.Name,
+			Email:         u.Email,
+			Role:          claims.RoleUser,
+			PasswordHash:  hash,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			Active:        !activationRequired,
+			CartReminders: true,
 		}
 
 		if err := user.Create(ctx, db, usr); err != nil {
@@ -199,7 +275,7 @@ func HandleSignup(db *sqlx.DB, session *scs.SessionManager, activationRequired b
 		}
 
 		if !activationRequired {
-			if err := SaveUserSession(ctx, session, usr.ID, usr.Role); err != nil {
+			if err := SaveUserSession(ctx, db, session, r, usr.ID, usr.Role); err != nil {
 				return fmt.Errorf("store user[%s] in session: %w", usr.ID, err)
 			}
 		}
@@ -207,3 +283,16 @@ func HandleSignup(db *sqlx.DB, session *scs.SessionManager, activationRequired b
 		return web.Respond(ctx, w, usr, http.StatusCreated)
 	}
 }
+
+func withQuery(rawURL string, key string, value string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
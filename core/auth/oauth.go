@@ -2,50 +2,238 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"golang.org/x/oauth2"
 )
 
+// UserInfo is the user authenticated by a provider. Verified reports whether
+// the provider vouched for the email: only verified emails can log in to an
+// existing account.
 type UserInfo struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Verified bool   `json:"-"`
 }
 
+// ClaimMapping maps the claims of a provider to the user info. The Name and
+// Email claims are tried in order until one holds a value, while Verified
+// must hold true for the email to be trusted. It defaults to email_verified
+// for OIDC providers. SkipVerification accepts unverified emails instead,
+// for providers without such a claim, but only to sign up new users.
+type ClaimMapping struct {
+	Name             []string
+	Email            []string
+	Verified         string
+	SkipVerification bool
+}
+
+// ProviderConfig configures either an OIDC provider, discovered from its
+// issuer URL, or a plain OAuth2 provider, reached through its endpoints.
 type ProviderConfig struct {
 	Name        string
 	Client      string
 	Secret      string
 	URL         string
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+	EmailsURL   string
 	RedirectURL string
+	Scopes      []string
+	Claims      ClaimMapping
 }
 
 type Provider struct {
 	*oauth2.Config
 	*oidc.Provider
+	userInfoURL string
+	emailsURL   string
+	claims      ClaimMapping
 }
 
 func MakeProviders(ctx context.Context, cfg []ProviderConfig) (map[string]Provider, error) {
 	provs := make(map[string]Provider)
 
 	for _, c := range cfg {
-		p, err := oidc.NewProvider(ctx, c.URL)
-		if err != nil {
-			return nil, fmt.Errorf("loading provider for [%s]: %w", c.Name, err)
-		}
-
-		provs[c.Name] = Provider{
-			Provider: p,
+		prov := Provider{
 			Config: &oauth2.Config{
 				ClientID:     c.Client,
 				ClientSecret: c.Secret,
-				Endpoint:     p.Endpoint(),
 				RedirectURL:  c.RedirectURL,
-				Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+				Scopes:       c.Scopes,
 			},
+			userInfoURL: c.UserInfoURL,
+			emailsURL:   c.EmailsURL,
+			claims:      c.Claims,
+		}
+
+		if len(prov.claims.Name) == 0 {
+			prov.claims.Name = []string{"name"}
+		}
+		if len(prov.claims.Email) == 0 {
+			prov.claims.Email = []string{"email"}
 		}
+
+		if c.URL != "" {
+			p, err := oidc.NewProvider(ctx, c.URL)
+			if err != nil {
+				return nil, fmt.Errorf("loading provider for [%s]: %w", c.Name, err)
+			}
+			prov.Provider = p
+			prov.Config.Endpoint = p.Endpoint()
+			if len(prov.Config.Scopes) == 0 {
+				prov.Config.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+			}
+			if prov.claims.Verified == "" && !prov.claims.SkipVerification {
+				prov.claims.Verified = "email_verified"
+			}
+		} else {
+			if c.AuthURL == "" || c.TokenURL == "" || c.UserInfoURL == "" {
+				return nil, fmt.Errorf("provider [%s] needs either an issuer url or auth, token and user info urls", c.Name)
+			}
+			prov.Config.Endpoint = oauth2.Endpoint{AuthURL: c.AuthURL, TokenURL: c.TokenURL}
+		}
+
+		provs[c.Name] = prov
 	}
 
 	return provs, nil
 }
+
+// UserInfo returns the user authenticated by the token. OIDC providers are
+// trusted through their id token, completed by their user info endpoint when
+// it lacks some claims. Plain OAuth2 providers are asked through the
+// configured user info endpoint.
+func (p Provider) UserInfo(ctx context.Context, tok *oauth2.Token) (UserInfo, error) {
+	claims := make(map[string]any)
+
+	if p.Provider != nil {
+		rawIDTok, ok := tok.Extra("id_token").(string)
+		if !ok {
+			return UserInfo{}, errors.New("id token not present")
+		}
+
+		verifier := p.Verifier(&oidc.Config{ClientID: p.ClientID})
+		idTok, err := verifier.Verify(ctx, rawIDTok)
+		if err != nil {
+			return UserInfo{}, fmt.Errorf("id token not valid: %w", err)
+		}
+
+		if err := idTok.Claims(&claims); err != nil {
+			return UserInfo{}, fmt.Errorf("extracting id token claims: %w", err)
+		}
+
+		if p.lookup(claims, p.claims.Name) == "" || p.lookup(claims, p.claims.Email) == "" {
+			info, err := p.Provider.UserInfo(ctx, oauth2.StaticTokenSource(tok))
+			if err == nil {
+				extra := make(map[string]any)
+				if err := info.Claims(&extra); err == nil {
+					for k, v := range extra {
+						if _, ok := claims[k]; !ok {
+							claims[k] = v
+						}
+					}
+				}
+			}
+		}
+	} else {
+		if err := p.get(ctx, tok, p.userInfoURL, &claims); err != nil {
+			return UserInfo{}, fmt.Errorf("fetching user info: %w", err)
+		}
+	}
+
+	info := UserInfo{
+		Name:  p.lookup(claims, p.claims.Name),
+		Email: p.lookup(claims, p.claims.Email),
+	}
+
+	if p.claims.Verified != "" {
+		info.Verified = verified(claims[p.claims.Verified])
+	}
+
+	if !info.Verified && p.emailsURL != "" {
+		email, err := p.primaryEmail(ctx, tok)
+		if err != nil {
+			return UserInfo{}, err
+		}
+		info.Email = email
+		info.Verified = true
+	}
+
+	if !info.Verified && !p.claims.SkipVerification {
+		info.Email = ""
+	}
+
+	if info.Name == "" || info.Email == "" {
+		return UserInfo{}, fmt.Errorf("name or verified email not found in claims: %+v", claims)
+	}
+
+	return info, nil
+}
+
+// primaryEmail reads the emails list of providers keeping the email out of
+// the user info, like GitHub does for private emails.
+func (p Provider) primaryEmail(ctx context.Context, tok *oauth2.Token) (string, error) {
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+
+	if err := p.get(ctx, tok, p.emailsURL, &emails); err != nil {
+		return "", fmt.Errorf("fetching user emails: %w", err)
+	}
+
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return e.Email, nil
+		}
+	}
+
+	return "", errors.New("no primary verified email found")
+}
+
+func (p Provider) get(ctx context.Context, tok *oauth2.Token, url string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.Config.Client(ctx, tok).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dst)
+}
+
+func (p Provider) lookup(claims map[string]any, keys []string) string {
+	for _, k := range keys {
+		if v, ok := claims[k].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// verified reads a verification claim, which some providers send as a string.
+func verified(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true" || v == "1"
+	}
+	return false
+}
@@ -8,40 +8,152 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/alexedwards/scs/v2"
 	"github.com/irsalhamdi/e-commerce-video/api/web"
 	"github.com/irsalhamdi/e-commerce-video/api/weberr"
 	"github.com/irsalhamdi/e-commerce-video/core/claims"
+	"github.com/irsalhamdi/e-commerce-video/core/user"
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/irsalhamdi/e-commerce-video/validate"
+	"github.com/jmoiron/sqlx"
 )
 
 const userKey = "userID"
 const roleKey = "role"
+const sessionKey = "sessionID"
+
+// Session is a login of a user, listed so that they can revoke it.
+type Session struct {
+	ID         string    `json:"id" db:"session_id"`
+	UserID     string    `json:"userId" db:"user_id"`
+	Device     string    `json:"device" db:"device"`
+	UserAgent  string    `json:"userAgent" db:"user_agent"`
+	IP         string    `json:"ip" db:"ip"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+	LastSeenAt time.Time `json:"lastSeenAt" db:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expiresAt" db:"expires_at"`
+	Current    bool      `json:"current" db:"-"`
+}
+
+func SaveUserSession(ctx context.Context, db sqlx.ExtContext, session *scs.SessionManager, r *http.Request, userID string, role string) error {
+	now := time.Now().UTC()
+	s := Session{
+		ID:         validate.GenerateID(),
+		UserID:     userID,
+		Device:     device(r.UserAgent()),
+		UserAgent:  r.UserAgent(),
+		IP:         clientIP(r),
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
 
-func SaveUserSession(ctx context.Context, session *scs.SessionManager, userID string, role string) error {
 	session.Put(ctx, userKey, userID)
 	session.Put(ctx, roleKey, role)
+	session.Put(ctx, sessionKey, s.ID)
 	if err := session.RenewToken(ctx); err != nil {
 		return fmt.Errorf("renewing token: %w", err)
 	}
+
+	s.ExpiresAt = session.Deadline(ctx).UTC()
+	if err := CreateSession(ctx, db, s); err != nil {
+		return fmt.Errorf("recording session of user[%s]: %w", userID, err)
+	}
+
 	return nil
 }
 
-func Authenticate(s *scs.SessionManager) web.Middleware {
+// LoginPending is returned by a login that still needs the second step.
+type LoginPending struct {
+	TOTPRequired bool `json:"totpRequired"`
+}
+
+// Login stores the user in the session. Users with two-factor authentication
+// turned on are only marked as half-authenticated, until HandleVerifyTOTP
+// checks their code: Login reports whether this second step is needed.
+func Login(ctx context.Context, db sqlx.ExtContext, session *scs.SessionManager, r *http.Request, u user.User) (bool, error) {
+	if !u.TOTPEnabled {
+		return false, SaveUserSession(ctx, db, session, r, u.ID, u.Role)
+	}
+
+	session.Put(ctx, pendingKey, u.ID)
+	if err := session.RenewToken(ctx); err != nil {
+		return false, fmt.Errorf("renewing token: %w", err)
+	}
+
+	return true, nil
+}
+
+func SessionUser(ctx context.Context, session *scs.SessionManager) (string, bool) {
+	uid, ok := session.Get(ctx, userKey).(string)
+	return uid, ok
+}
+
+// SessionID returns the id of the login stored in the session, if any.
+func SessionID(ctx context.Context, session *scs.SessionManager) string {
+	return session.GetString(ctx, sessionKey)
+}
+
+// loadClaims reads the claims of the logged user from the session, checking
+// that their login was not revoked in the meantime. A user deleted or
+// deactivated since the login is logged out, while a changed role replaces
+// the one in the session.
+func loadClaims(ctx context.Context, db sqlx.ExtContext, s *scs.SessionManager, users *UserCache) (claims.Claims, user.User, error) {
+	uid, ok := s.Get(ctx, userKey).(string)
+	if !ok {
+		return claims.Claims{}, user.User{}, weberr.NotAuthorized(errors.New("no userID in session"))
+	}
+
+	role, ok := s.Get(ctx, roleKey).(string)
+	if !ok {
+		return claims.Claims{}, user.User{}, weberr.NotAuthorized(errors.New("no user role in session"))
+	}
+
+	sid, ok := s.Get(ctx, sessionKey).(string)
+	if !ok {
+		return claims.Claims{}, user.User{}, weberr.NotAuthorized(errors.New("no sessionID in session"))
+	}
+
+	if err := TouchSession(ctx, db, sid, uid); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return claims.Claims{}, user.User{}, weberr.NotAuthorized(fmt.Errorf("session of user[%s] revoked: %w", uid, err))
+		}
+		return claims.Claims{}, user.User{}, err
+	}
+
+	u, err := users.Fetch(ctx, db, uid)
+	if err != nil && !errors.Is(err, database.ErrDBNotFound) {
+		return claims.Claims{}, user.User{}, fmt.Errorf("fetching user[%s]: %w", uid, err)
+	}
+
+	if err != nil || !u.Active {
+		if err := DeleteSession(ctx, db, sid, uid); err != nil {
+			return claims.Claims{}, user.User{}, err
+		}
+		if err := s.Destroy(ctx); err != nil {
+			return claims.Claims{}, user.User{}, fmt.Errorf("destroying session: %w", err)
+		}
+		return claims.Claims{}, user.User{}, weberr.NotAuthorized(fmt.Errorf("user[%s] is deleted or not active", uid))
+	}
+
+	if u.Role != role {
+		s.Put(ctx, roleKey, u.Role)
+	}
+
+	return claims.Claims{UserID: uid, Role: u.Role}, u, nil
+}
+
+func Authenticate(db *sqlx.DB, s *scs.SessionManager, users *UserCache) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-			uid, ok := s.Get(ctx, userKey).(string)
-			if !ok {
-				return weberr.NotAuthorized(errors.New("no userID in session"))
-			}
-
-			role, ok := s.Get(ctx, roleKey).(string)
-			if !ok {
-				return weberr.NotAuthorized(errors.New("no user role in session"))
+			clm, _, err := loadClaims(ctx, db, s, users)
+			if err != nil {
+				return err
 			}
 
-			ctx = claims.Set(ctx, claims.Claims{UserID: uid, Role: role})
+			ctx = claims.Set(ctx, clm)
 
 			return handler(ctx, w, r)
 		}
@@ -50,18 +162,27 @@ func Authenticate(s *scs.SessionManager) web.Middleware {
 	return m
 }
 
-func Admin(s *scs.SessionManager) web.Middleware {
+// Admin lets through admins only. When totpRequired is set, admins must also
+// have two-factor authentication turned on.
+func Admin(db *sqlx.DB, s *scs.SessionManager, users *UserCache, totpRequired bool) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-			role, ok := s.Get(ctx, roleKey).(string)
-			if !ok {
-				return weberr.NotAuthorized(errors.New("no user role in session"))
+			clm, u, err := loadClaims(ctx, db, s, users)
+			if err != nil {
+				return err
+			}
+
+			if clm.Role != claims.RoleAdmin {
+				return weberr.NotAuthorized(fmt.Errorf("user role is not admin: %s", clm.Role))
 			}
 
-			if role != claims.RoleAdmin {
-				return weberr.NotAuthorized(fmt.Errorf("user role is not admin: %s", role))
+			if totpRequired && !u.TOTPEnabled {
+				err := errors.New("two-factor authentication required")
+				return weberr.NewError(err, err.Error(), http.StatusForbidden)
 			}
 
+			ctx = claims.Set(ctx, clm)
+
 			return handler(ctx, w, r)
 		}
 		return h
@@ -69,6 +190,39 @@ func Admin(s *scs.SessionManager) web.Middleware {
 	return m
 }
 
+// device gives a short description of the client, good enough for users to
+// recognize their logins.
+func device(userAgent string) string {
+	platforms := []struct {
+		match string
+		name  string
+	}{
+		{"iPad", "iPad"},
+		{"iPhone", "iPhone"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Macintosh", "Mac"},
+		{"CrOS", "Chromebook"},
+		{"Linux", "Linux"},
+	}
+
+	for _, p := range platforms {
+		if strings.Contains(userAgent, p.match) {
+			return p.name
+		}
+	}
+
+	return "Unknown"
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func LoadAndSave(s *scs.SessionManager) web.Middleware {
 	m := func(handler web.Handler) web.Handler {
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
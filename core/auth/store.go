@@ -0,0 +1,248 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/jmoiron/sqlx"
+)
+
+func CreateSession(ctx context.Context, db sqlx.ExtContext, s Session) error {
+	const q = `
+	INSERT INTO user_sessions
+		(session_id, user_id, device, user_agent, ip, created_at, last_seen_at, expires_at)
+	VALUES
+		(:session_id, :user_id, :device, :user_agent, :ip, :created_at, :last_seen_at, :expires_at)`
+
+	if err := database.NamedExecContext(ctx, db, q, s); err != nil {
+		return fmt.Errorf("inserting session: %w", err)
+	}
+
+	return nil
+}
+
+func FetchSessions(ctx context.Context, db sqlx.ExtContext, userID string) ([]Session, error) {
+	in := struct {
+		UserID string    `db:"user_id"`
+		Now    time.Time `db:"now"`
+	}{
+		UserID: userID,
+		Now:    time.Now().UTC(),
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		user_sessions
+	WHERE
+		user_id = :user_id AND
+		expires_at > :now
+	ORDER BY
+		last_seen_at DESC`
+
+	sessions := []Session{}
+	if err := database.NamedQuerySlice(ctx, db, q, in, &sessions); err != nil {
+		return nil, fmt.Errorf("selecting sessions of user[%s]: %w", userID, err)
+	}
+
+	return sessions, nil
+}
+
+// touchInterval is how often last_seen_at is updated, so that authenticated
+// requests mostly only read their session.
+const touchInterval = time.Minute
+
+// TouchSession records that the session was just used, writing it only when
+// it was last seen more than touchInterval ago. It returns
+// database.ErrDBNotFound when the session was revoked or expired.
+func TouchSession(ctx context.Context, db sqlx.ExtContext, id string, userID string) error {
+	now := time.Now().UTC()
+	in := struct {
+		ID     string    `db:"session_id"`
+		UserID string    `db:"user_id"`
+		Now    time.Time `db:"now"`
+		Stale  time.Time `db:"stale"`
+	}{
+		ID:     id,
+		UserID: userID,
+		Now:    now,
+		Stale:  now.Add(-touchInterval),
+	}
+
+	const q = `
+	WITH touched AS (
+		UPDATE user_sessions
+		SET
+			last_seen_at = :now
+		WHERE
+			session_id = :session_id AND
+			user_id = :user_id AND
+			expires_at > :now AND
+			last_seen_at < :stale
+	)
+	SELECT
+		session_id
+	FROM
+		user_sessions
+	WHERE
+		session_id = :session_id AND
+		user_id = :user_id AND
+		expires_at > :now`
+
+	var out struct {
+		ID string `db:"session_id"`
+	}
+	if err := database.NamedQueryStruct(ctx, db, q, in, &out); err != nil {
+		return fmt.Errorf("touching session[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+func DeleteSession(ctx context.Context, db sqlx.ExtContext, id string, userID string) error {
+	in := struct {
+		ID     string `db:"session_id"`
+		UserID string `db:"user_id"`
+	}{
+		ID:     id,
+		UserID: userID,
+	}
+
+	const q = `
+	DELETE FROM
+		user_sessions
+	WHERE
+		session_id = :session_id AND
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("deleting session[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+// DeleteSessionsByUser revokes all the sessions of the user but the one
+// passed as except, which can be left empty.
+func DeleteSessionsByUser(ctx context.Context, db sqlx.ExtContext, userID string, except string) error {
+	in := struct {
+		UserID string `db:"user_id"`
+		Except string `db:"except"`
+	}{
+		UserID: userID,
+		Except: except,
+	}
+
+	const q = `
+	DELETE FROM
+		user_sessions
+	WHERE
+		user_id = :user_id AND
+		CAST(session_id AS TEXT) != :except`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("deleting sessions of user[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+func DeleteExpiredSessions(ctx context.Context, db sqlx.ExtContext) error {
+	in := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: time.Now().UTC(),
+	}
+
+	const q = `
+	DELETE FROM
+		user_sessions
+	WHERE
+		expires_at <= :now`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("deleting expired sessions: %w", err)
+	}
+
+	return nil
+}
+
+func CreateRecoveryCodes(ctx context.Context, db sqlx.ExtContext, userID string, hashes [][]byte) error {
+	now := time.Now().UTC()
+	for _, h := range hashes {
+		in := struct {
+			UserID    string    `db:"user_id"`
+			CodeHash  []byte    `db:"code_hash"`
+			CreatedAt time.Time `db:"created_at"`
+		}{
+			UserID:    userID,
+			CodeHash:  h,
+			CreatedAt: now,
+		}
+
+		const q = `
+		INSERT INTO totp_recovery_codes
+			(user_id, code_hash, created_at)
+		VALUES
+			(:user_id, :code_hash, :created_at)`
+
+		if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+			return fmt.Errorf("inserting recovery code of user[%s]: %w", userID, err)
+		}
+	}
+
+	return nil
+}
+
+func DeleteRecoveryCodes(ctx context.Context, db sqlx.ExtContext, userID string) error {
+	in := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	DELETE FROM
+		totp_recovery_codes
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("deleting recovery codes of user[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode consumes the recovery code. It returns database.ErrDBNotFound
+// when the user has no such code.
+func UseRecoveryCode(ctx context.Context, db sqlx.ExtContext, userID string, hash []byte) error {
+	in := struct {
+		UserID   string `db:"user_id"`
+		CodeHash []byte `db:"code_hash"`
+	}{
+		UserID:   userID,
+		CodeHash: hash,
+	}
+
+	const q = `
+	DELETE FROM
+		totp_recovery_codes
+	WHERE
+		user_id = :user_id AND
+		code_hash = :code_hash
+	RETURNING
+		user_id`
+
+	var out struct {
+		UserID string `db:"user_id"`
+	}
+	if err := database.NamedQueryStruct(ctx, db, q, in, &out); err != nil {
+		return fmt.Errorf("using recovery code of user[%s]: %w", userID, err)
+	}
+
+	return nil
+}
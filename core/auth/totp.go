@@ -0,0 +1,286 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/irsalhamdi/e-commerce-video/api/web"
+	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/core/claims"
+	"github.com/irsalhamdi/e-commerce-video/core/user"
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/irsalhamdi/e-commerce-video/random"
+	"github.com/irsalhamdi/e-commerce-video/rate"
+	"github.com/irsalhamdi/e-commerce-video/totp"
+	"github.com/irsalhamdi/e-commerce-video/validate"
+	"github.com/jmoiron/sqlx"
+)
+
+const pendingKey = "pendingUserID"
+
+const (
+	totpIssuer         = "Govod"
+	recoveryCodes      = 10
+	recoveryCodeLength = 10
+)
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type TOTPRecovery struct {
+	Codes []string `json:"recoveryCodes"`
+}
+
+type TOTPCode struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// HandleVerifyTOTP completes the login of a half-authenticated user, with
+// either a code of their authenticator app or one of their recovery codes.
+func HandleVerifyTOTP(db *sqlx.DB, session *scs.SessionManager) web.Handler {
+	limiter := rate.NewLimiter(5, 10, float64(rate.Every(30*time.Second)))
+
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var in TOTPCode
+		if err := web.Decode(w, r, &in); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(in); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		uid, ok := session.Get(ctx, pendingKey).(string)
+		if !ok {
+			return weberr.NotAuthorized(errors.New("no pending login in session"))
+		}
+
+		if !limiter.Check(uid) {
+			err := errors.New("too many requests")
+			return weberr.NewError(err, err.Error(), http.StatusTooManyRequests)
+		}
+
+		u, err := user.Fetch(ctx, db, uid)
+		if err != nil {
+			return fmt.Errorf("fetching user[%s]: %w", uid, err)
+		}
+
+		if err := checkCode(ctx, db, u, in.Code); err != nil {
+			return err
+		}
+
+		session.Remove(ctx, pendingKey)
+		if err := SaveUserSession(ctx, db, session, r, u.ID, u.Role); err != nil {
+			return fmt.Errorf("store user[%s] in session: %w", u.ID, err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+// HandleEnrollTOTP generates a new two-factor secret for the user, which is
+// turned on only once HandleConfirmTOTP receives a code generated from it.
+func HandleEnrollTOTP(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		u, err := user.Fetch(ctx, db, clm.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching user[%s]: %w", clm.UserID, err)
+		}
+
+		if u.TOTPEnabled {
+			err := errors.New("two-factor authentication already enabled")
+			return weberr.NewError(err, err.Error(), http.StatusConflict)
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			return fmt.Errorf("generating two-factor secret: %w", err)
+		}
+
+		if err := user.UpdateTOTP(ctx, db, u.ID, &secret, false); err != nil {
+			return err
+		}
+
+		enr := TOTPEnrollment{
+			Secret: secret,
+			URI:    totp.URI(totpIssuer, u.Email, secret),
+		}
+
+		return web.Respond(ctx, w, enr, http.StatusCreated)
+	}
+}
+
+// HandleConfirmTOTP turns two-factor authentication on, returning the
+// recovery codes of the user. They are stored hashed and shown only here.
+func HandleConfirmTOTP(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var in TOTPCode
+		if err := web.Decode(w, r, &in); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(in); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		u, err := user.Fetch(ctx, db, clm.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching user[%s]: %w", clm.UserID, err)
+		}
+
+		if u.TOTPEnabled {
+			err := errors.New("two-factor authentication already enabled")
+			return weberr.NewError(err, err.Error(), http.StatusConflict)
+		}
+
+		if u.TOTPSecret == nil {
+			err := errors.New("two-factor authentication not enrolled")
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		step, err := totp.Validate(*u.TOTPSecret, in.Code, time.Now())
+		if err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		rec := TOTPRecovery{Codes: make([]string, recoveryCodes)}
+		hashes := make([][]byte, recoveryCodes)
+		for i := range rec.Codes {
+			code, err := random.StringSecure(recoveryCodeLength)
+			if err != nil {
+				return fmt.Errorf("generating recovery code: %w", err)
+			}
+			rec.Codes[i] = code
+			hashes[i] = hashCode(code)
+		}
+
+		err = database.Transaction(db, func(tx sqlx.ExtContext) error {
+			if err := user.UpdateTOTP(ctx, tx, u.ID, u.TOTPSecret, true); err != nil {
+				return err
+			}
+
+			if err := user.UpdateTOTPStep(ctx, tx, u.ID, step); err != nil {
+				return err
+			}
+
+			if err := DeleteRecoveryCodes(ctx, tx, u.ID); err != nil {
+				return err
+			}
+
+			return CreateRecoveryCodes(ctx, tx, u.ID, hashes)
+		})
+		if err != nil {
+			return fmt.Errorf("enabling two-factor authentication of user[%s]: %w", u.ID, err)
+		}
+
+		return web.Respond(ctx, w, rec, http.StatusOK)
+	}
+}
+
+// HandleDisableTOTP turns two-factor authentication off, after checking a
+// code of the user.
+func HandleDisableTOTP(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var in TOTPCode
+		if err := web.Decode(w, r, &in); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(in); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		u, err := user.Fetch(ctx, db, clm.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching user[%s]: %w", clm.UserID, err)
+		}
+
+		if !u.TOTPEnabled {
+			err := errors.New("two-factor authentication not enabled")
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		if err := checkCode(ctx, db, u, in.Code); err != nil {
+			return err
+		}
+
+		err = database.Transaction(db, func(tx sqlx.ExtContext) error {
+			if err := user.UpdateTOTP(ctx, tx, u.ID, nil, false); err != nil {
+				return err
+			}
+
+			return DeleteRecoveryCodes(ctx, tx, u.ID)
+		})
+		if err != nil {
+			return fmt.Errorf("disabling two-factor authentication of user[%s]: %w", u.ID, err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+// checkCode accepts a code of the authenticator app of the user, never used
+// before, or one of their recovery codes, which is then consumed.
+func checkCode(ctx context.Context, db sqlx.ExtContext, u user.User, code string) error {
+	invalid := weberr.NotAuthorized(fmt.Errorf("invalid two-factor code for user[%s]", u.ID))
+
+	if !u.TOTPEnabled || u.TOTPSecret == nil {
+		return invalid
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == recoveryCodeLength {
+		if err := UseRecoveryCode(ctx, db, u.ID, hashCode(code)); err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return invalid
+			}
+			return err
+		}
+		return nil
+	}
+
+	step, err := totp.Validate(*u.TOTPSecret, code, time.Now())
+	if err != nil {
+		if errors.Is(err, totp.ErrInvalidCode) {
+			return invalid
+		}
+		return err
+	}
+
+	if err := user.UpdateTOTPStep(ctx, db, u.ID, step); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return invalid
+		}
+		return err
+	}
+
+	return nil
+}
+
+func hashCode(code string) []byte {
+	h := sha256.Sum256([]byte(code))
+	return h[:]
+}
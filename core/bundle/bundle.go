@@ -0,0 +1,80 @@
+package bundle
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/money"
+)
+
+type Bundle struct {
+	ID          string          `json:"id" db:"bundle_id"`
+	Name        string          `json:"name" db:"name"`
+	Description string          `json:"description" db:"description"`
+	ImageURL    string          `json:"imageUrl" db:"image_url"`
+	Prices      money.Prices    `json:"prices" db:"prices"`
+	CreatedAt   time.Time       `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updatedAt" db:"updated_at"`
+	Version     int             `json:"-" db:"version"`
+	Courses     []course.Course `json:"courses" db:"-"`
+}
+
+type BundleNew struct {
+	Name        string       `json:"name" validate:"required"`
+	Description string       `json:"description" validate:"required"`
+	Prices      money.Prices `json:"prices" validate:"required,min=1,dive,keys,iso4217,endkeys,gte=0,lte=1000000"`
+	ImageURL    string       `json:"imageUrl" validate:"required"`
+	CourseIDs   []string     `json:"courseIds" validate:"required,min=2,unique,dive,uuid"`
+}
+
+type BundleUp struct {
+	Name        *string      `json:"name"`
+	Description *string      `json:"description"`
+	Prices      money.Prices `json:"prices" validate:"omitempty,min=1,dive,keys,iso4217,endkeys,gte=0,lte=1000000"`
+	ImageURL    *string      `json:"imageUrl"`
+	CourseIDs   []string     `json:"courseIds" validate:"omitempty,min=2,unique,dive,uuid"`
+}
+
+// Split spreads the bundle price over its courses proportionally to their
+// own prices, so that every course gets its share as an order item.
+func (b Bundle) Split(currency string) ([]int, error) {
+	price, ok := b.Prices[currency]
+	if !ok {
+		return nil, fmt.Errorf("bundle %s is not available in %s", b.Name, currency)
+	}
+
+	if len(b.Courses) == 0 {
+		return nil, fmt.Errorf("bundle %s has no courses", b.Name)
+	}
+
+	weights := make([]int, len(b.Courses))
+	var tot int
+	for i, c := range b.Courses {
+		weights[i] = c.Prices[currency]
+		tot += weights[i]
+	}
+
+	if tot == 0 {
+		for i := range weights {
+			weights[i] = 1
+		}
+		tot = len(weights)
+	}
+
+	out := make([]int, len(weights))
+	left := price
+	for i, w := range weights {
+		out[i] = price * w / tot
+		left -= out[i]
+	}
+
+	for i := 0; left > 0; i = (i + 1) % len(out) {
+		if weights[i] > 0 {
+			out[i]++
+			left--
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,75 @@
+package bundle
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/money"
+)
+
+func TestSplit(t *testing.T) {
+	courses := []course.Course{
+		{Prices: money.Prices{"USD": 1000, "EUR": 0}},
+		{Prices: money.Prices{"USD": 3000, "EUR": 0}},
+		{Prices: money.Prices{"USD": 0, "EUR": 0}},
+	}
+
+	tests := []struct {
+		name   string
+		bundle Bundle
+		cur    string
+		exp    []int
+		err    bool
+	}{
+		{
+			name:   "proportional",
+			bundle: Bundle{Prices: money.Prices{"USD": 2000}, Courses: courses},
+			cur:    "USD",
+			exp:    []int{500, 1500, 0},
+		},
+		{
+			name:   "remainder",
+			bundle: Bundle{Prices: money.Prices{"USD": 2001}, Courses: courses},
+			cur:    "USD",
+			exp:    []int{501, 1500, 0},
+		},
+		{
+			name:   "free courses",
+			bundle: Bundle{Prices: money.Prices{"EUR": 1000}, Courses: courses},
+			cur:    "EUR",
+			exp:    []int{334, 333, 333},
+		},
+		{
+			name:   "currency not available",
+			bundle: Bundle{Prices: money.Prices{"USD": 2000}, Courses: courses},
+			cur:    "GBP",
+			err:    true,
+		},
+		{
+			name:   "no courses",
+			bundle: Bundle{Prices: money.Prices{"USD": 2000}},
+			cur:    "USD",
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.bundle.Split(tt.cur)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(got, tt.exp); diff != "" {
+				t.Fatalf("wrong split amounts. Diff: \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/api/web"
+	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/irsalhamdi/e-commerce-video/validate"
+	"github.com/jmoiron/sqlx"
+)
+
+func checkCourses(ctx context.Context, db *sqlx.DB, ids []string) error {
+	for _, id := range ids {
+		if _, err := course.Fetch(ctx, db, id); err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				err := fmt.Errorf("course[%s] does not exist", id)
+				return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+			}
+			return fmt.Errorf("fetching course[%s]: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func HandleCreate(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var b BundleNew
+		if err := web.Decode(w, r, &b); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(b); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		if err := checkCourses(ctx, db, b.CourseIDs); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+
+		bundle := Bundle{
+			ID:          validate.GenerateID(),
+			Name:        b.Name,
+			Description: b.Description,
+			Prices:      b.Prices,
+			ImageURL:    b.ImageURL,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+
+		err := database.Transaction(db, func(tx sqlx.ExtContext) error {
+			if err := Create(ctx, tx, bundle); err != nil {
+				return err
+			}
+			return UpdateCourses(ctx, tx, bundle.ID, b.CourseIDs)
+		})
+		if err != nil {
+			return fmt.Errorf("creating bundle: %w", err)
+		}
+
+		if bundle.Courses, err = FetchCourses(ctx, db, bundle.ID); err != nil {
+			return fmt.Errorf("fetching courses of bundle[%s]: %w", bundle.ID, err)
+		}
+
+		return web.Respond(ctx, w, bundle, http.StatusCreated)
+	}
+}
+
+func HandleUpdate(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		bundleID := web.Param(r, "id")
+
+		if err := validate.CheckID(bundleID); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		var bup BundleUp
+		if err := web.Decode(w, r, &bup); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(bup); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		if err := checkCourses(ctx, db, bup.CourseIDs); err != nil {
+			return err
+		}
+
+		bundle, err := Fetch(ctx, db, bundleID)
+		if err != nil {
+			err := fmt.Errorf("fetching passed bundle[%s]: %w", bundleID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		if bup.Name != nil {
+			bundle.Name = *bup.Name
+		}
+		if bup.Description != nil {
+			bundle.Description = *bup.Description
+		}
+		if bup.Prices != nil {
+			bundle.Prices = bup.Prices
+		}
+		if bup.ImageURL != nil {
+			bundle.ImageURL = *bup.ImageURL
+		}
+		bundle.UpdatedAt = time.Now().UTC()
+
+		err = database.Transaction(db, func(tx sqlx.ExtContext) error {
+			if bundle, err = Update(ctx, tx, bundle); err != nil {
+				return err
+			}
+			if bup.CourseIDs == nil {
+				return nil
+			}
+			return UpdateCourses(ctx, tx, bundle.ID, bup.CourseIDs)
+		})
+		if err != nil {
+			return fmt.Errorf("updating bundle[%s]: %w", bundleID, err)
+		}
+
+		if bundle.Courses, err = FetchCourses(ctx, db, bundle.ID); err != nil {
+			return fmt.Errorf("fetching courses of bundle[%s]: %w", bundle.ID, err)
+		}
+
+		return web.Respond(ctx, w, bundle, http.StatusOK)
+	}
+}
+
+func HandleDelete(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		bundleID := web.Param(r, "id")
+
+		if err := validate.CheckID(bundleID); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		if err := Delete(ctx, db, bundleID); err != nil {
+			return fmt.Errorf("deleting bundle[%s]: %w", bundleID, err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+func HandleList(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		bundles, err := FetchAll(ctx, db)
+		if err != nil {
+			return fmt.Errorf("fetching all bundles: %w", err)
+		}
+
+		for i := range bundles {
+			if bundles[i].Courses, err = FetchCourses(ctx, db, bundles[i].ID); err != nil {
+				return fmt.Errorf("fetching courses of bundle[%s]: %w", bundles[i].ID, err)
+			}
+		}
+
+		return web.Respond(ctx, w, bundles, http.StatusOK)
+	}
+}
+
+func HandleShow(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		bundleID := web.Param(r, "id")
+
+		if err := validate.CheckID(bundleID); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		bundle, err := Fetch(ctx, db, bundleID)
+		if err != nil {
+			err := fmt.Errorf("fetching bundle[%s]: %w", bundleID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		if bundle.Courses, err = FetchCourses(ctx, db, bundle.ID); err != nil {
+			return fmt.Errorf("fetching courses of bundle[%s]: %w", bundle.ID, err)
+		}
+
+		return web.Respond(ctx, w, bundle, http.StatusOK)
+	}
+}
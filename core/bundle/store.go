@@ -0,0 +1,183 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/jmoiron/sqlx"
+)
+
+func Create(ctx context.Context, db sqlx.ExtContext, bundle Bundle) error {
+	const q = `
+	INSERT INTO bundles
+		(bundle_id, name, description, prices, image_url, created_at, updated_at)
+	VALUES
+	(:bundle_id, :name, :description, :prices, :image_url, :created_at, :updated_at)`
+
+	if err := database.NamedExecContext(ctx, db, q, bundle); err != nil {
+		return fmt.Errorf("inserting bundle: %w", err)
+	}
+
+	return nil
+}
+
+func Update(ctx context.Context, db sqlx.ExtContext, bundle Bundle) (Bundle, error) {
+	const q = `
+	UPDATE bundles
+	SET
+		name = :name,
+		description = :description,
+		prices = :prices,
+		image_url = :image_url,
+		updated_at = :updated_at,
+		version = version + 1
+	WHERE
+		bundle_id = :bundle_id AND
+		version = :version
+	RETURNING version`
+
+	v := struct {
+		Version int `db:"version"`
+	}{}
+
+	if err := database.NamedQueryStruct(ctx, db, q, bundle, &v); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return Bundle{}, fmt.Errorf("updating bundle[%s]: version conflict", bundle.ID)
+		}
+		return Bundle{}, fmt.Errorf("updating bundle[%s]: %w", bundle.ID, err)
+	}
+
+	bundle.Version = v.Version
+
+	return bundle, nil
+}
+
+func Delete(ctx context.Context, db sqlx.ExtContext, id string) error {
+	in := struct {
+		ID string `db:"bundle_id"`
+	}{
+		ID: id,
+	}
+
+	const q = `
+	DELETE FROM
+		bundles
+	WHERE
+		bundle_id = :bundle_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("deleting bundle[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+func Fetch(ctx context.Context, db sqlx.ExtContext, id string) (Bundle, error) {
+	in := struct {
+		ID string `db:"bundle_id"`
+	}{
+		ID: id,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		bundles
+	WHERE
+		bundle_id = :bundle_id`
+
+	var b Bundle
+	if err := database.NamedQueryStruct(ctx, db, q, in, &b); err != nil {
+		return Bundle{}, fmt.Errorf("selecting bundle[%s]: %w", id, err)
+	}
+
+	return b, nil
+}
+
+func FetchAll(ctx context.Context, db sqlx.ExtContext) ([]Bundle, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		bundles
+	ORDER BY
+		bundle_id`
+
+	bs := []Bundle{}
+	if err := database.NamedQuerySlice(ctx, db, q, struct{}{}, &bs); err != nil {
+		return nil, fmt.Errorf("selecting all bundles: %w", err)
+	}
+
+	return bs, nil
+}
+
+func FetchCourses(ctx context.Context, db sqlx.ExtContext, id string) ([]course.Course, error) {
+	in := struct {
+		ID string `db:"bundle_id"`
+	}{
+		ID: id,
+	}
+
+	const q = `
+	SELECT
+		c.*
+	FROM
+		bundle_courses AS bc
+	INNER JOIN
+		courses AS c ON bc.course_id = c.course_id
+	WHERE
+		bc.bundle_id = :bundle_id
+	ORDER BY
+		c.course_id`
+
+	cs := []course.Course{}
+	if err := database.NamedQuerySlice(ctx, db, q, in, &cs); err != nil {
+		return nil, fmt.Errorf("selecting courses of bundle[%s]: %w", id, err)
+	}
+
+	return cs, nil
+}
+
+func UpdateCourses(ctx context.Context, db sqlx.ExtContext, id string, courseIDs []string) error {
+	in := struct {
+		ID string `db:"bundle_id"`
+	}{
+		ID: id,
+	}
+
+	const del = `
+	DELETE FROM
+		bundle_courses
+	WHERE
+		bundle_id = :bundle_id`
+
+	if err := database.NamedExecContext(ctx, db, del, in); err != nil {
+		return fmt.Errorf("deleting courses of bundle[%s]: %w", id, err)
+	}
+
+	const ins = `
+	INSERT INTO bundle_courses
+		(bundle_id, course_id)
+	VALUES
+	(:bundle_id, :course_id)`
+
+	for _, courseID := range courseIDs {
+		bc := struct {
+			ID       string `db:"bundle_id"`
+			CourseID string `db:"course_id"`
+		}{
+			ID:       id,
+			CourseID: courseID,
+		}
+
+		if err := database.NamedExecContext(ctx, db, ins, bc); err != nil {
+			return fmt.Errorf("inserting course[%s] in bundle[%s]: %w", courseID, id, err)
+		}
+	}
+
+	return nil
+}
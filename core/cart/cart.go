@@ -2,23 +2,53 @@ package cart
 
 import (
 	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/money"
 )
 
 type Cart struct {
-	UserID    string    `json:"-" db:"user_id"`
-	CreatedAt time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
-	Version   int       `json:"-" db:"version"`
-	Items     []Item    `json:"items" db:"-"`
+	UserID     string     `json:"-" db:"user_id"`
+	CouponID   *string    `json:"couponId,omitempty" db:"coupon_id"`
+	RemindedAt *time.Time `json:"-" db:"reminded_at"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updatedAt" db:"updated_at"`
+	Version    int        `json:"-" db:"version"`
+	Items      []Item     `json:"items" db:"-"`
+	Bundles    []Bundle   `json:"bundles" db:"-"`
 }
 
+// Item keeps the prices the course had when it was added to the cart, so
+// that a later change of price can be detected before checking out.
 type Item struct {
-	UserID    string    `json:"-" db:"user_id"`
-	CourseID  string    `json:"courseId" db:"course_id"`
-	CreatedAt time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+	UserID       string       `json:"-" db:"user_id"`
+	CourseID     string       `json:"courseId" db:"course_id"`
+	Prices       money.Prices `json:"prices" db:"prices"`
+	PriceChanged bool         `json:"priceChanged" db:"-"`
+	CreatedAt    time.Time    `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time    `json:"updatedAt" db:"updated_at"`
 }
 
 type ItemNew struct {
 	CourseID string `json:"courseId" db:"course_id"`
 }
+
+type Bundle struct {
+	UserID       string       `json:"-" db:"user_id"`
+	BundleID     string       `json:"bundleId" db:"bundle_id"`
+	Prices       money.Prices `json:"prices" db:"prices"`
+	PriceChanged bool         `json:"priceChanged" db:"-"`
+	CreatedAt    time.Time    `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time    `json:"updatedAt" db:"updated_at"`
+}
+
+type BundleNew struct {
+	BundleID string `json:"bundleId" db:"bundle_id" validate:"required,uuid"`
+}
+
+// Abandoned is a cart left untouched since UpdatedAt, with the user to remind.
+type Abandoned struct {
+	UserID    string    `db:"user_id"`
+	Name      string    `db:"name"`
+	Email     string    `db:"email"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
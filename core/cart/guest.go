@@ -0,0 +1,214 @@
+package cart
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/irsalhamdi/e-commerce-video/api/web"
+	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/core/auth"
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/irsalhamdi/e-commerce-video/validate"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+)
+
+const guestKey = "guestCart"
+
+// The guest cart is kept in the session as json, so that its items do not
+// need to be registered with the session codec.
+func guestItems(ctx context.Context, session *scs.SessionManager) ([]Item, error) {
+	b, ok := session.Get(ctx, guestKey).([]byte)
+	if !ok {
+		return []Item{}, nil
+	}
+
+	items := []Item{}
+	if err := json.Unmarshal(b, &items); err != nil {
+		return nil, fmt.Errorf("decoding guest cart: %w", err)
+	}
+
+	return items, nil
+}
+
+func saveGuestItems(ctx context.Context, session *scs.SessionManager, items []Item) error {
+	b, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("encoding guest cart: %w", err)
+	}
+
+	session.Put(ctx, guestKey, b)
+	return nil
+}
+
+func HandleShowGuest(db *sqlx.DB, session *scs.SessionManager) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		items, err := guestItems(ctx, session)
+		if err != nil {
+			return err
+		}
+
+		cart := Cart{Items: items, Bundles: []Bundle{}}
+		if err := checkPrices(ctx, db, &cart); err != nil {
+			return fmt.Errorf("checking prices of guest cart: %w", err)
+		}
+
+		return web.Respond(ctx, w, cart, http.StatusOK)
+	}
+}
+
+func HandleCreateGuestItem(db *sqlx.DB, session *scs.SessionManager) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var itnew ItemNew
+		if err := web.Decode(w, r, &itnew); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.CheckID(itnew.CourseID); err != nil {
+			return weberr.BadRequest(fmt.Errorf("passed id is not valid: %w", err))
+		}
+
+		c, err := course.Fetch(ctx, db, itnew.CourseID)
+		if err != nil {
+			err := fmt.Errorf("fetching course[%s]: %w", itnew.CourseID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		items, err := guestItems(ctx, session)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		item := Item{
+			CourseID:  itnew.CourseID,
+			Prices:    c.Prices,
+			UpdatedAt: now,
+			CreatedAt: now,
+		}
+
+		found := false
+		for i := range items {
+			if items[i].CourseID == item.CourseID {
+				item.CreatedAt = items[i].CreatedAt
+				items[i] = item
+				found = true
+			}
+		}
+		if !found {
+			items = append(items, item)
+		}
+
+		if err := saveGuestItems(ctx, session, items); err != nil {
+			return err
+		}
+
+		return web.Respond(ctx, w, item, http.StatusCreated)
+	}
+}
+
+func HandleDeleteGuestItem(session *scs.SessionManager) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		courseID := web.Param(r, "course_id")
+
+		if err := validate.CheckID(courseID); err != nil {
+			return weberr.BadRequest(fmt.Errorf("passed id is not valid: %w", err))
+		}
+
+		items, err := guestItems(ctx, session)
+		if err != nil {
+			return err
+		}
+
+		kept := make([]Item, 0, len(items))
+		for _, it := range items {
+			if it.CourseID != courseID {
+				kept = append(kept, it)
+			}
+		}
+
+		if err := saveGuestItems(ctx, session, kept); err != nil {
+			return err
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+func mergeGuest(ctx context.Context, db *sqlx.DB, userID string, items []Item) error {
+	owned, err := course.FetchByOwner(ctx, db, userID)
+	if err != nil {
+		return fmt.Errorf("fetching courses owned by user[%s]: %w", userID, err)
+	}
+
+	skip := make(map[string]bool, len(owned))
+	for _, c := range owned {
+		skip[c.ID] = true
+	}
+
+	return database.Transaction(db, func(tx sqlx.ExtContext) error {
+		if _, err := Upsert(ctx, tx, userID); err != nil {
+			return fmt.Errorf("upserting user[%s] cart: %w", userID, err)
+		}
+
+		for _, it := range items {
+			if skip[it.CourseID] {
+				continue
+			}
+
+			it.UserID = userID
+			if err := CreateItem(ctx, tx, it); err != nil {
+				return fmt.Errorf("merging guest item[%s] into user[%s] cart: %w", it.CourseID, userID, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// MergeGuest moves the guest cart kept in the session into the cart of the
+// user as soon as the wrapped handler logs them in. A failed merge never
+// fails the login: it is logged, and the guest cart is kept and merged on
+// the next one.
+func MergeGuest(db *sqlx.DB, session *scs.SessionManager, log logrus.FieldLogger) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if err := handler(ctx, w, r); err != nil {
+				return err
+			}
+
+			userID, ok := auth.SessionUser(ctx, session)
+			if !ok {
+				return nil
+			}
+
+			items, err := guestItems(ctx, session)
+			if err != nil {
+				log.WithField("message", fmt.Errorf("reading guest cart of user[%s]: %w", userID, err)).Error("ERROR")
+				return nil
+			}
+			if len(items) == 0 {
+				return nil
+			}
+
+			if err := mergeGuest(ctx, db, userID, items); err != nil {
+				log.WithField("message", fmt.Errorf("merging guest cart of user[%s]: %w", userID, err)).Error("ERROR")
+				return nil
+			}
+
+			session.Remove(ctx, guestKey)
+			return nil
+		}
+		return h
+	}
+	return m
+}
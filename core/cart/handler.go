@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/irsalhamdi/e-commerce-video/api/web"
 	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/core/bundle"
 	"github.com/irsalhamdi/e-commerce-video/core/claims"
+	"github.com/irsalhamdi/e-commerce-video/core/coupon"
 	"github.com/irsalhamdi/e-commerce-video/core/course"
 	"github.com/irsalhamdi/e-commerce-video/database"
 	"github.com/irsalhamdi/e-commerce-video/validate"
@@ -26,7 +29,7 @@ func HandleShow(db *sqlx.DB) web.Handler {
 		cart, err := Fetch(ctx, db, clm.UserID)
 		if err != nil {
 			if errors.Is(err, database.ErrDBNotFound) {
-				return web.Respond(ctx, w, Cart{Items: []Item{}}, http.StatusOK)
+				return web.Respond(ctx, w, Cart{Items: []Item{}, Bundles: []Bundle{}}, http.StatusOK)
 			}
 			return fmt.Errorf("fetching user[%s] cart: %w", clm.UserID, err)
 		}
@@ -36,6 +39,15 @@ func HandleShow(db *sqlx.DB) web.Handler {
 			return fmt.Errorf("fetching user[%s] cart items: %w", clm.UserID, err)
 		}
 
+		cart.Bundles, err = FetchBundles(ctx, db, clm.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching user[%s] cart bundles: %w", clm.UserID, err)
+		}
+
+		if err := checkPrices(ctx, db, &cart); err != nil {
+			return fmt.Errorf("checking prices of user[%s] cart: %w", clm.UserID, err)
+		}
+
 		return web.Respond(ctx, w, cart, http.StatusOK)
 	}
 }
@@ -83,6 +95,15 @@ func HandleCreateItem(db *sqlx.DB) web.Handler {
 			}
 		}
 
+		c, err := course.Fetch(ctx, db, itnew.CourseID)
+		if err != nil {
+			err := fmt.Errorf("fetching course[%s]: %w", itnew.CourseID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
 		if _, err := Upsert(ctx, db, clm.UserID); err != nil {
 			return fmt.Errorf("upserting user[%s] cart: %w", clm.UserID, err)
 		}
@@ -91,6 +112,7 @@ func HandleCreateItem(db *sqlx.DB) web.Handler {
 		item := Item{
 			UserID:    clm.UserID,
 			CourseID:  itnew.CourseID,
+			Prices:    c.Prices,
 			UpdatedAt: now,
 			CreatedAt: now,
 		}
@@ -127,3 +149,167 @@ func HandleDeleteItem(db *sqlx.DB) web.Handler {
 		return web.Respond(ctx, w, nil, http.StatusNoContent)
 	}
 }
+
+func HandleCreateBundle(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var bnew BundleNew
+		if err := web.Decode(w, r, &bnew); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(bnew); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		bdl, err := bundle.Fetch(ctx, db, bnew.BundleID)
+		if err != nil {
+			err := fmt.Errorf("fetching bundle[%s]: %w", bnew.BundleID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		if _, err := Upsert(ctx, db, clm.UserID); err != nil {
+			return fmt.Errorf("upserting user[%s] cart: %w", clm.UserID, err)
+		}
+
+		now := time.Now().UTC()
+		b := Bundle{
+			UserID:    clm.UserID,
+			BundleID:  bnew.BundleID,
+			Prices:    bdl.Prices,
+			UpdatedAt: now,
+			CreatedAt: now,
+		}
+
+		if err := CreateBundle(ctx, db, b); err != nil {
+			return fmt.Errorf("creating cart bundle[%s] for user[%s]: %w", b.BundleID, clm.UserID, err)
+		}
+
+		return web.Respond(ctx, w, b, http.StatusCreated)
+	}
+}
+
+func HandleDeleteBundle(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		bundleID := web.Param(r, "bundle_id")
+
+		if err := validate.CheckID(bundleID); err != nil {
+			return weberr.BadRequest(fmt.Errorf("passed id is not valid: %w", err))
+		}
+
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		if _, err := Upsert(ctx, db, clm.UserID); err != nil {
+			return fmt.Errorf("upserting user[%s] cart: %w", clm.UserID, err)
+		}
+
+		if err := DeleteBundle(ctx, db, clm.UserID, bundleID); err != nil {
+			return fmt.Errorf("deleting user[%s] cart bundle: %w", clm.UserID, err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+func HandleApplyCoupon(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var ca coupon.Apply
+		if err := web.Decode(w, r, &ca); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(ca); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		cp, err := coupon.FetchByCode(ctx, db, strings.ToUpper(ca.Code))
+		if err != nil {
+			err := fmt.Errorf("fetching coupon[%s]: %w", ca.Code, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		if err := cp.Check(time.Now().UTC()); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		cart, err := Upsert(ctx, db, clm.UserID)
+		if err != nil {
+			return fmt.Errorf("upserting user[%s] cart: %w", clm.UserID, err)
+		}
+
+		if err := UpdateCoupon(ctx, db, clm.UserID, &cp.ID); err != nil {
+			return fmt.Errorf("applying coupon[%s] to user[%s] cart: %w", cp.ID, clm.UserID, err)
+		}
+		cart.CouponID = &cp.ID
+
+		cart.Items, err = FetchItems(ctx, db, clm.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching user[%s] cart items: %w", clm.UserID, err)
+		}
+
+		cart.Bundles, err = FetchBundles(ctx, db, clm.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching user[%s] cart bundles: %w", clm.UserID, err)
+		}
+
+		if err := checkPrices(ctx, db, &cart); err != nil {
+			return fmt.Errorf("checking prices of user[%s] cart: %w", clm.UserID, err)
+		}
+
+		return web.Respond(ctx, w, cart, http.StatusOK)
+	}
+}
+
+func HandleRemoveCoupon(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		if err := UpdateCoupon(ctx, db, clm.UserID, nil); err != nil {
+			return fmt.Errorf("removing coupon from user[%s] cart: %w", clm.UserID, err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+// checkPrices flags the cart entries whose price changed since they were added.
+func checkPrices(ctx context.Context, db sqlx.ExtContext, cart *Cart) error {
+	for i, it := range cart.Items {
+		c, err := course.Fetch(ctx, db, it.CourseID)
+		if err != nil {
+			return fmt.Errorf("fetching course[%s]: %w", it.CourseID, err)
+		}
+		cart.Items[i].PriceChanged = !it.Prices.Equal(c.Prices)
+	}
+
+	for i, cb := range cart.Bundles {
+		b, err := bundle.Fetch(ctx, db, cb.BundleID)
+		if err != nil {
+			return fmt.Errorf("fetching bundle[%s]: %w", cb.BundleID, err)
+		}
+		cart.Bundles[i].PriceChanged = !cb.Prices.Equal(b.Prices)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package cart
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/core/bundle"
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/core/token"
+	"github.com/jmoiron/sqlx"
+)
+
+// RemindAbandoned emails the users whose cart was left untouched for longer
+// than idle. Each cart is reminded once: a new reminder is sent only after
+// the cart changes again. A cart that cannot be reminded doesn't stop the
+// others, it is retried on the next run.
+func RemindAbandoned(ctx context.Context, db *sqlx.DB, mailer token.Mailer, idle time.Duration) error {
+	now := time.Now().UTC()
+
+	carts, err := FetchAbandoned(ctx, db, now.Add(-idle))
+	if err != nil {
+		return fmt.Errorf("fetching abandoned carts: %w", err)
+	}
+
+	var failed int
+	var lastErr error
+	for _, c := range carts {
+		if err := remind(ctx, db, mailer, c, now); err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cart reminders failed, last: %w", failed, len(carts), lastErr)
+	}
+
+	return nil
+}
+
+func remind(ctx context.Context, db sqlx.ExtContext, mailer token.Mailer, c Abandoned, now time.Time) error {
+	names, err := contents(ctx, db, c.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := mailer.SendCartReminder(c.Email, c.Name, names); err != nil {
+		return fmt.Errorf("reminding user[%s] of their cart: %w", c.UserID, err)
+	}
+
+	return UpdateReminded(ctx, db, c.UserID, now)
+}
+
+func contents(ctx context.Context, db sqlx.ExtContext, userID string) ([]string, error) {
+	items, err := FetchItems(ctx, db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching user[%s] cart items: %w", userID, err)
+	}
+
+	bundles, err := FetchBundles(ctx, db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching user[%s] cart bundles: %w", userID, err)
+	}
+
+	names := make([]string, 0, len(items)+len(bundles))
+	for _, it := range items {
+		c, err := course.Fetch(ctx, db, it.CourseID)
+		if err != nil {
+			return nil, fmt.Errorf("fetching course[%s]: %w", it.CourseID, err)
+		}
+		names = append(names, c.Name)
+	}
+
+	for _, cb := range bundles {
+		b, err := bundle.Fetch(ctx, db, cb.BundleID)
+		if err != nil {
+			return nil, fmt.Errorf("fetching bundle[%s]: %w", cb.BundleID, err)
+		}
+		names = append(names, b.Name)
+	}
+
+	return names, nil
+}
@@ -94,6 +94,89 @@ func Update(ctx context.Context, db sqlx.ExtContext, cart Cart) (Cart, error) {
 	return cart, nil
 }
 
+func UpdateCoupon(ctx context.Context, db sqlx.ExtContext, userID string, couponID *string) error {
+	in := struct {
+		UserID   string  `db:"user_id"`
+		CouponID *string `db:"coupon_id"`
+	}{
+		UserID:   userID,
+		CouponID: couponID,
+	}
+
+	const q = `
+	UPDATE carts
+	SET
+		coupon_id = :coupon_id,
+		version = version + 1
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("updating coupon of cart of user[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
+// FetchAbandoned returns the non empty carts untouched since before, whose
+// owners were not reminded of them since their last change.
+func FetchAbandoned(ctx context.Context, db sqlx.ExtContext, before time.Time) ([]Abandoned, error) {
+	in := struct {
+		Before time.Time `db:"before"`
+	}{
+		Before: before,
+	}
+
+	const q = `
+	SELECT
+		c.user_id, u.name, u.email, c.updated_at
+	FROM
+		carts AS c
+	INNER JOIN
+		users AS u ON u.user_id = c.user_id
+	WHERE
+		c.updated_at < :before AND
+		(c.reminded_at IS NULL OR c.reminded_at < c.updated_at) AND
+		u.active AND
+		u.cart_reminders AND
+		(
+			EXISTS (SELECT 1 FROM cart_items AS i WHERE i.user_id = c.user_id) OR
+			EXISTS (SELECT 1 FROM cart_bundles AS b WHERE b.user_id = c.user_id)
+		)
+	ORDER BY
+		c.updated_at`
+
+	abandoned := []Abandoned{}
+	if err := database.NamedQuerySlice(ctx, db, q, in, &abandoned); err != nil {
+		return nil, fmt.Errorf("selecting carts untouched since %s: %w", before, err)
+	}
+
+	return abandoned, nil
+}
+
+func UpdateReminded(ctx context.Context, db sqlx.ExtContext, userID string, at time.Time) error {
+	in := struct {
+		UserID     string    `db:"user_id"`
+		RemindedAt time.Time `db:"reminded_at"`
+	}{
+		UserID:     userID,
+		RemindedAt: at,
+	}
+
+	const q = `
+	UPDATE carts
+	SET
+		reminded_at = :reminded_at
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("updating reminder of cart of user[%s]: %w", userID, err)
+	}
+
+	return nil
+}
+
 func Upsert(ctx context.Context, db sqlx.ExtContext, userID string) (Cart, error) {
 	cart, err := Fetch(ctx, db, userID)
 	if err != nil {
@@ -144,9 +227,12 @@ func FetchItems(ctx context.Context, db sqlx.ExtContext, userID string) ([]Item,
 func CreateItem(ctx context.Context, db sqlx.ExtContext, item Item) error {
 	const q = `
 	INSERT INTO cart_items
-		(user_id, course_id, created_at, updated_at)
+		(user_id, course_id, prices, created_at, updated_at)
 	VALUES
-	(:user_id, :course_id, :created_at, :updated_at)`
+		(:user_id, :course_id, :prices, :created_at, :updated_at)
+	ON CONFLICT (user_id, course_id) DO UPDATE SET
+		prices = EXCLUDED.prices,
+		updated_at = EXCLUDED.updated_at`
 
 	if err := database.NamedExecContext(ctx, db, q, item); err != nil {
 		return fmt.Errorf("inserting cart item: %w", err)
@@ -176,3 +262,67 @@ func DeleteItem(ctx context.Context, db sqlx.ExtContext, userID string, courseID
 
 	return nil
 }
+
+func FetchBundles(ctx context.Context, db sqlx.ExtContext, userID string) ([]Bundle, error) {
+	in := struct {
+		ID string `db:"user_id"`
+	}{
+		ID: userID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		cart_bundles
+	WHERE
+		user_id = :user_id
+	ORDER BY
+		bundle_id`
+
+	cb := []Bundle{}
+	if err := database.NamedQuerySlice(ctx, db, q, in, &cb); err != nil {
+		return nil, fmt.Errorf("selecting cart bundles of user[%s]: %w", userID, err)
+	}
+
+	return cb, nil
+}
+
+func CreateBundle(ctx context.Context, db sqlx.ExtContext, b Bundle) error {
+	const q = `
+	INSERT INTO cart_bundles
+		(user_id, bundle_id, prices, created_at, updated_at)
+	VALUES
+		(:user_id, :bundle_id, :prices, :created_at, :updated_at)
+	ON CONFLICT (user_id, bundle_id) DO UPDATE SET
+		prices = EXCLUDED.prices,
+		updated_at = EXCLUDED.updated_at`
+
+	if err := database.NamedExecContext(ctx, db, q, b); err != nil {
+		return fmt.Errorf("inserting cart bundle: %w", err)
+	}
+
+	return nil
+}
+
+func DeleteBundle(ctx context.Context, db sqlx.ExtContext, userID string, bundleID string) error {
+	in := struct {
+		UserID   string `db:"user_id"`
+		BundleID string `db:"bundle_id"`
+	}{
+		UserID:   userID,
+		BundleID: bundleID,
+	}
+
+	const q = `
+	DELETE FROM
+		cart_bundles
+	WHERE
+		user_id = :user_id AND bundle_id = :bundle_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("deleting cart bundle: %w", err)
+	}
+
+	return nil
+}
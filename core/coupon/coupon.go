@@ -0,0 +1,156 @@
+package coupon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/money"
+	"github.com/lib/pq"
+)
+
+type Kind string
+
+const (
+	Percentage Kind = "percentage"
+	Fixed      Kind = "fixed"
+)
+
+var (
+	ErrExpired     = errors.New("coupon is expired")
+	ErrExhausted   = errors.New("coupon has reached its maximum number of redemptions")
+	ErrNotEligible = errors.New("coupon does not apply to any item")
+)
+
+type Coupon struct {
+	ID             string         `json:"id" db:"coupon_id"`
+	Code           string         `json:"code" db:"code"`
+	Kind           Kind           `json:"kind" db:"kind"`
+	Percentage     int            `json:"percentage" db:"percentage"`
+	Amounts        money.Prices   `json:"amounts" db:"amounts"`
+	CourseIDs      pq.StringArray `json:"courseIds" db:"course_ids"`
+	MaxRedemptions int            `json:"maxRedemptions" db:"max_redemptions"`
+	Redemptions    int            `json:"redemptions" db:"redemptions"`
+	ExpiresAt      time.Time      `json:"expiresAt" db:"expires_at"`
+	CreatedAt      time.Time      `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time      `json:"updatedAt" db:"updated_at"`
+	Version        int            `json:"-" db:"version"`
+}
+
+type CouponNew struct {
+	Code           string       `json:"code" validate:"required,alphanum,max=32"`
+	Kind           Kind         `json:"kind" validate:"required,oneof=percentage fixed"`
+	Percentage     int          `json:"percentage" validate:"required_if=Kind percentage,gte=0,lte=100"`
+	Amounts        money.Prices `json:"amounts" validate:"required_if=Kind fixed,dive,keys,iso4217,endkeys,gt=0"`
+	CourseIDs      []string     `json:"courseIds" validate:"dive,uuid"`
+	MaxRedemptions int          `json:"maxRedemptions" validate:"gte=0"`
+	ExpiresAt      time.Time    `json:"expiresAt" validate:"required"`
+}
+
+type CouponUp struct {
+	Percentage     *int         `json:"percentage" validate:"omitempty,gte=0,lte=100"`
+	Amounts        money.Prices `json:"amounts" validate:"omitempty,dive,keys,iso4217,endkeys,gt=0"`
+	CourseIDs      []string     `json:"courseIds" validate:"omitempty,dive,uuid"`
+	MaxRedemptions *int         `json:"maxRedemptions" validate:"omitempty,gte=0"`
+	ExpiresAt      *time.Time   `json:"expiresAt"`
+}
+
+type Apply struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type Line struct {
+	CourseID string
+	Amount   int
+}
+
+func (c Coupon) Check(now time.Time) error {
+	if !now.Before(c.ExpiresAt) {
+		return ErrExpired
+	}
+
+	if c.MaxRedemptions > 0 && c.Redemptions >= c.MaxRedemptions {
+		return ErrExhausted
+	}
+
+	return nil
+}
+
+func (c Coupon) Applies(courseID string) bool {
+	if len(c.CourseIDs) == 0 {
+		return true
+	}
+
+	for _, id := range c.CourseIDs {
+		if id == courseID {
+			return true
+		}
+	}
+	return false
+}
+
+// Discount returns the lines with their amounts reduced by the coupon.
+// Fixed discounts are spread over the eligible lines proportionally to
+// their amounts, so that each line can still be charged on its own.
+func (c Coupon) Discount(currency string, lines []Line) ([]Line, error) {
+	out := make([]Line, len(lines))
+	copy(out, lines)
+
+	var eligible int
+	for _, l := range out {
+		if c.Applies(l.CourseID) {
+			eligible += l.Amount
+		}
+	}
+
+	if eligible == 0 {
+		return nil, ErrNotEligible
+	}
+
+	switch c.Kind {
+	case Percentage:
+		for i, l := range out {
+			if c.Applies(l.CourseID) {
+				out[i].Amount -= l.Amount * c.Percentage / 100
+			}
+		}
+
+	case Fixed:
+		off, ok := c.Amounts[currency]
+		if !ok {
+			return nil, fmt.Errorf("coupon is not valid for payments in %s", currency)
+		}
+
+		if off > eligible {
+			off = eligible
+		}
+
+		left := off
+		for i, l := range out {
+			if c.Applies(l.CourseID) {
+				d := off * l.Amount / eligible
+				out[i].Amount -= d
+				left -= d
+			}
+		}
+
+		for i := range out {
+			if left == 0 {
+				break
+			}
+			if c.Applies(out[i].CourseID) && out[i].Amount > 0 {
+				d := left
+				if d > out[i].Amount {
+					d = out[i].Amount
+				}
+				out[i].Amount -= d
+				left -= d
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("coupon kind %s is not supported", c.Kind)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,73 @@
+package coupon
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/irsalhamdi/e-commerce-video/money"
+)
+
+func TestDiscount(t *testing.T) {
+	lines := []Line{
+		{CourseID: "a", Amount: 1000},
+		{CourseID: "b", Amount: 3000},
+		{CourseID: "c", Amount: 1},
+	}
+
+	tests := []struct {
+		name   string
+		coupon Coupon
+		exp    []Line
+		err    bool
+	}{
+		{
+			name:   "percentage",
+			coupon: Coupon{Kind: Percentage, Percentage: 25},
+			exp:    []Line{{"a", 750}, {"b", 2250}, {"c", 1}},
+		},
+		{
+			name:   "percentage restricted",
+			coupon: Coupon{Kind: Percentage, Percentage: 50, CourseIDs: []string{"b"}},
+			exp:    []Line{{"a", 1000}, {"b", 1500}, {"c", 1}},
+		},
+		{
+			name:   "fixed split proportionally",
+			coupon: Coupon{Kind: Fixed, Amounts: money.Prices{"USD": 1001}},
+			exp:    []Line{{"a", 749}, {"b", 2250}, {"c", 1}},
+		},
+		{
+			name:   "fixed above total",
+			coupon: Coupon{Kind: Fixed, Amounts: money.Prices{"USD": 5000}, CourseIDs: []string{"a", "c"}},
+			exp:    []Line{{"a", 0}, {"b", 3000}, {"c", 0}},
+		},
+		{
+			name:   "fixed other currency",
+			coupon: Coupon{Kind: Fixed, Amounts: money.Prices{"EUR": 100}},
+			err:    true,
+		},
+		{
+			name:   "not eligible",
+			coupon: Coupon{Kind: Percentage, Percentage: 10, CourseIDs: []string{"z"}},
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.coupon.Discount("USD", lines)
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(got, tt.exp); diff != "" {
+				t.Fatalf("wrong discounted lines. Diff: \n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package coupon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/api/web"
+	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/irsalhamdi/e-commerce-video/money"
+	"github.com/irsalhamdi/e-commerce-video/validate"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+func checkCourses(ctx context.Context, db *sqlx.DB, ids []string) error {
+	for _, id := range ids {
+		if _, err := course.Fetch(ctx, db, id); err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				err := fmt.Errorf("course[%s] does not exist", id)
+				return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+			}
+			return fmt.Errorf("fetching course[%s]: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func HandleCreate(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var c CouponNew
+		if err := web.Decode(w, r, &c); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(c); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		if err := checkCourses(ctx, db, c.CourseIDs); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+
+		coupon := Coupon{
+			ID:             validate.GenerateID(),
+			Code:           strings.ToUpper(c.Code),
+			Kind:           c.Kind,
+			Percentage:     c.Percentage,
+			Amounts:        c.Amounts,
+			CourseIDs:      append(pq.StringArray{}, c.CourseIDs...),
+			MaxRedemptions: c.MaxRedemptions,
+			ExpiresAt:      c.ExpiresAt.UTC(),
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+
+		if coupon.Kind == Fixed {
+			coupon.Percentage = 0
+		} else {
+			coupon.Amounts = money.Prices{}
+		}
+
+		if err := Create(ctx, db, coupon); err != nil {
+			if errors.Is(err, database.ErrDBDuplicatedEntry) {
+				return weberr.NewError(err, "passed coupon code already exists", http.StatusUnprocessableEntity)
+			}
+			return err
+		}
+
+		return web.Respond(ctx, w, coupon, http.StatusCreated)
+	}
+}
+
+func HandleUpdate(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		couponID := web.Param(r, "id")
+
+		if err := validate.CheckID(couponID); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		var cup CouponUp
+		if err := web.Decode(w, r, &cup); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(cup); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		if err := checkCourses(ctx, db, cup.CourseIDs); err != nil {
+			return err
+		}
+
+		coupon, err := Fetch(ctx, db, couponID)
+		if err != nil {
+			err := fmt.Errorf("fetching passed coupon[%s]: %w", couponID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		if cup.Percentage != nil && coupon.Kind == Percentage {
+			coupon.Percentage = *cup.Percentage
+		}
+		if cup.Amounts != nil && coupon.Kind == Fixed {
+			coupon.Amounts = cup.Amounts
+		}
+		if cup.CourseIDs != nil {
+			coupon.CourseIDs = cup.CourseIDs
+		}
+		if cup.MaxRedemptions != nil {
+			coupon.MaxRedemptions = *cup.MaxRedemptions
+		}
+		if cup.ExpiresAt != nil {
+			coupon.ExpiresAt = cup.ExpiresAt.UTC()
+		}
+		coupon.UpdatedAt = time.Now().UTC()
+
+		if coupon, err = Update(ctx, db, coupon); err != nil {
+			return fmt.Errorf("updating coupon[%s]: %w", coupon.ID, err)
+		}
+
+		return web.Respond(ctx, w, coupon, http.StatusOK)
+	}
+}
+
+func HandleDelete(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		couponID := web.Param(r, "id")
+
+		if err := validate.CheckID(couponID); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		if err := Delete(ctx, db, couponID); err != nil {
+			return fmt.Errorf("deleting coupon[%s]: %w", couponID, err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+func HandleList(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		coupons, err := FetchAll(ctx, db)
+		if err != nil {
+			return fmt.Errorf("fetching all coupons: %w", err)
+		}
+
+		return web.Respond(ctx, w, coupons, http.StatusOK)
+	}
+}
+
+func HandleShow(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		couponID := web.Param(r, "id")
+
+		if err := validate.CheckID(couponID); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		coupon, err := Fetch(ctx, db, couponID)
+		if err != nil {
+			err := fmt.Errorf("fetching coupon[%s]: %w", couponID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		return web.Respond(ctx, w, coupon, http.StatusOK)
+	}
+}
@@ -0,0 +1,194 @@
+package coupon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/jmoiron/sqlx"
+)
+
+func Create(ctx context.Context, db sqlx.ExtContext, coupon Coupon) error {
+	const q = `
+	INSERT INTO coupons
+		(coupon_id, code, kind, percentage, amounts, course_ids, max_redemptions, expires_at, created_at, updated_at)
+	VALUES
+	(:coupon_id, :code, :kind, :percentage, :amounts, :course_ids, :max_redemptions, :expires_at, :created_at, :updated_at)`
+
+	if err := database.NamedExecContext(ctx, db, q, coupon); err != nil {
+		return fmt.Errorf("inserting coupon: %w", err)
+	}
+
+	return nil
+}
+
+func Update(ctx context.Context, db sqlx.ExtContext, coupon Coupon) (Coupon, error) {
+	const q = `
+	UPDATE coupons
+	SET
+		percentage = :percentage,
+		amounts = :amounts,
+		course_ids = :course_ids,
+		max_redemptions = :max_redemptions,
+		expires_at = :expires_at,
+		updated_at = :updated_at,
+		version = version + 1
+	WHERE
+		coupon_id = :coupon_id AND
+		version = :version
+	RETURNING version`
+
+	v := struct {
+		Version int `db:"version"`
+	}{}
+
+	if err := database.NamedQueryStruct(ctx, db, q, coupon, &v); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return Coupon{}, fmt.Errorf("updating coupon[%s]: version conflict", coupon.ID)
+		}
+		return Coupon{}, fmt.Errorf("updating coupon[%s]: %w", coupon.ID, err)
+	}
+
+	coupon.Version = v.Version
+
+	return coupon, nil
+}
+
+func Delete(ctx context.Context, db sqlx.ExtContext, id string) error {
+	in := struct {
+		ID string `db:"coupon_id"`
+	}{
+		ID: id,
+	}
+
+	const q = `
+	DELETE FROM
+		coupons
+	WHERE
+		coupon_id = :coupon_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("deleting coupon[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+// Redeem takes a redemption of the coupon, failing with ErrExhausted when it
+// has none left.
+func Redeem(ctx context.Context, db sqlx.ExtContext, id string) error {
+	in := struct {
+		ID string `db:"coupon_id"`
+	}{
+		ID: id,
+	}
+
+	const q = `
+	UPDATE coupons
+	SET
+		redemptions = redemptions + 1
+	WHERE
+		coupon_id = :coupon_id AND
+		(max_redemptions = 0 OR redemptions < max_redemptions)
+	RETURNING
+		coupon_id`
+
+	var out struct {
+		ID string `db:"coupon_id"`
+	}
+	if err := database.NamedQueryStruct(ctx, db, q, in, &out); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return fmt.Errorf("redeeming coupon[%s]: %w", id, ErrExhausted)
+		}
+		return fmt.Errorf("redeeming coupon[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+// Release gives back a redemption taken by a checkout that expired.
+func Release(ctx context.Context, db sqlx.ExtContext, id string) error {
+	in := struct {
+		ID string `db:"coupon_id"`
+	}{
+		ID: id,
+	}
+
+	const q = `
+	UPDATE coupons
+	SET
+		redemptions = redemptions - 1
+	WHERE
+		coupon_id = :coupon_id AND
+		redemptions > 0`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("releasing coupon[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+func Fetch(ctx context.Context, db sqlx.ExtContext, id string) (Coupon, error) {
+	in := struct {
+		ID string `db:"coupon_id"`
+	}{
+		ID: id,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		coupons
+	WHERE
+		coupon_id = :coupon_id`
+
+	var c Coupon
+	if err := database.NamedQueryStruct(ctx, db, q, in, &c); err != nil {
+		return Coupon{}, fmt.Errorf("selecting coupon[%s]: %w", id, err)
+	}
+
+	return c, nil
+}
+
+func FetchByCode(ctx context.Context, db sqlx.ExtContext, code string) (Coupon, error) {
+	in := struct {
+		Code string `db:"code"`
+	}{
+		Code: code,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		coupons
+	WHERE
+		code = :code`
+
+	var c Coupon
+	if err := database.NamedQueryStruct(ctx, db, q, in, &c); err != nil {
+		return Coupon{}, fmt.Errorf("selecting coupon with code[%s]: %w", code, err)
+	}
+
+	return c, nil
+}
+
+func FetchAll(ctx context.Context, db sqlx.ExtContext) ([]Coupon, error) {
+	const q = `
+	SELECT
+		*
+	FROM
+		coupons
+	ORDER BY
+		created_at DESC`
+
+	cs := []Coupon{}
+	if err := database.NamedQuerySlice(ctx, db, q, struct{}{}, &cs); err != nil {
+		return nil, fmt.Errorf("selecting all coupons: %w", err)
+	}
+
+	return cs, nil
+}
@@ -1,28 +1,32 @@
 package course
 
-import "time"
+import (
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/money"
+)
 
 type Course struct {
-	ID          string    `json:"id" db:"course_id"`
-	Name        string    `json:"name" db:"name"`
-	Description string    `json:"description" db:"description"`
-	ImageURL    string    `json:"imageUrl" db:"image_url"`
-	Price       int       `json:"price" db:"price"`
-	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
-	Version     int       `json:"-" db:"version"`
+	ID          string       `json:"id" db:"course_id"`
+	Name        string       `json:"name" db:"name"`
+	Description string       `json:"description" db:"description"`
+	ImageURL    string       `json:"imageUrl" db:"image_url"`
+	Prices      money.Prices `json:"prices" db:"prices"`
+	CreatedAt   time.Time    `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time    `json:"updatedAt" db:"updated_at"`
+	Version     int          `json:"-" db:"version"`
 }
 
 type CourseNew struct {
-	Name        string `json:"name" validate:"required"`
-	Description string `json:"description" validate:"required"`
-	Price       int    `json:"price" validate:"required,gte=0,lte=10000"`
-	ImageURL    string `json:"imageUrl" validate:"required"`
+	Name        string       `json:"name" validate:"required"`
+	Description string       `json:"description" validate:"required"`
+	Prices      money.Prices `json:"prices" validate:"required,min=1,dive,keys,iso4217,endkeys,gte=0,lte=1000000"`
+	ImageURL    string       `json:"imageUrl" validate:"required"`
 }
 
 type CourseUp struct {
-	Name        *string `json:"name"`
-	Description *string `json:"description"`
-	Price       *int    `json:"price" validate:"omitempty,gte=0,lte=10000"`
-	ImageURL    *string `json:"imageUrl"`
+	Name        *string      `json:"name"`
+	Description *string      `json:"description"`
+	Prices      money.Prices `json:"prices" validate:"omitempty,min=1,dive,keys,iso4217,endkeys,gte=0,lte=1000000"`
+	ImageURL    *string      `json:"imageUrl"`
 }
@@ -32,7 +32,7 @@ func HandleCreate(db *sqlx.DB) web.Handler {
 			ID:          validate.GenerateID(),
 			Name:        c.Name,
 			Description: c.Description,
-			Price:       c.Price,
+			Prices:      c.Prices,
 			ImageURL:    c.ImageURL,
 			CreatedAt:   now,
 			UpdatedAt:   now,
@@ -81,8 +81,8 @@ func HandleUpdate(db *sqlx.DB) web.Handler {
 		if cup.Description != nil {
 			course.Description = *cup.Description
 		}
-		if cup.Price != nil {
-			course.Price = *cup.Price
+		if cup.Prices != nil {
+			course.Prices = cup.Prices
 		}
 		if cup.ImageURL != nil {
 			course.ImageURL = *cup.ImageURL
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/irsalhamdi/e-commerce-video/database"
 	"github.com/jmoiron/sqlx"
@@ -12,9 +13,9 @@ import (
 func Create(ctx context.Context, db sqlx.ExtContext, course Course) error {
 	const q = `
 	INSERT INTO courses
-		(course_id, name, description, price, image_url, created_at, updated_at)
+		(course_id, name, description, prices, image_url, created_at, updated_at)
 	VALUES
-	(:course_id, :name, :description, :price, :image_url, :created_at, :updated_at)`
+	(:course_id, :name, :description, :prices, :image_url, :created_at, :updated_at)`
 
 	if err := database.NamedExecContext(ctx, db, q, course); err != nil {
 		return fmt.Errorf("inserting course: %w", err)
@@ -29,7 +30,7 @@ func Update(ctx context.Context, db sqlx.ExtContext, course Course) (Course, err
 	SET
 		name = :name,
 		description = :description,
-		price = :price,
+		prices = :prices,
 		image_url = :image_url,
 		updated_at = :updated_at,
 		version = version + 1
@@ -114,7 +115,7 @@ func FetchByOwner(ctx context.Context, db sqlx.ExtContext, userID string) ([]Cou
 		courses AS c ON i.course_id = c.course_id
 	WHERE
 		o.status = :status AND
-		o.user_id = :user_id
+		o.owner_id = :user_id
 	ORDER BY
 		c.course_id`
 
@@ -128,28 +129,51 @@ func FetchByOwner(ctx context.Context, db sqlx.ExtContext, userID string) ([]Cou
 
 func FetchOwned(ctx context.Context, db sqlx.ExtContext, courseID string, userID string) (Course, error) {
 	in := struct {
-		UserID   string `db:"user_id"`
-		CourseID string `db:"course_id"`
-		Status   string `db:"status"`
+		UserID   string    `db:"user_id"`
+		CourseID string    `db:"course_id"`
+		Status   string    `db:"status"`
+		Active   string    `db:"active"`
+		Trialing string    `db:"trialing"`
+		Now      time.Time `db:"now"`
 	}{
 		UserID:   userID,
 		CourseID: courseID,
 		Status:   "success",
+		Active:   "active",
+		Trialing: "trialing",
+		Now:      time.Now().UTC(),
 	}
 
 	const q = `
 	SELECT
 		c.*
 	FROM
-		orders AS o
-	INNER JOIN
-		order_items AS i ON i.order_id = o.order_id
-	INNER JOIN
-		courses AS c ON i.course_id = c.course_id
+		courses AS c
 	WHERE
-		o.status = :status AND
-		o.user_id = :user_id AND
-		c.course_id = :course_id`
+		c.course_id = :course_id AND (
+			EXISTS (
+				SELECT
+					1
+				FROM
+					orders AS o
+				INNER JOIN
+					order_items AS i ON i.order_id = o.order_id
+				WHERE
+					o.status = :status AND
+					o.owner_id = :user_id AND
+					i.course_id = c.course_id
+			) OR
+			EXISTS (
+				SELECT
+					1
+				FROM
+					subscriptions AS s
+				WHERE
+					s.user_id = :user_id AND
+					s.status IN (:active, :trialing) AND
+					s.period_end > :now
+			)
+		)`
 
 	var cs Course
 	if err := database.NamedQueryStruct(ctx, db, q, in, &cs); err != nil {
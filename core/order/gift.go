@@ -0,0 +1,162 @@
+package order
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/irsalhamdi/e-commerce-video/api/background"
+	"github.com/irsalhamdi/e-commerce-video/api/web"
+	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/core/auth"
+	"github.com/irsalhamdi/e-commerce-video/core/token"
+	"github.com/irsalhamdi/e-commerce-video/core/user"
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/irsalhamdi/e-commerce-video/validate"
+	"github.com/jmoiron/sqlx"
+)
+
+const giftKey = "gift"
+
+const giftTTL = 365 * 24 * time.Hour
+
+type gift struct {
+	Token string
+	Email string
+}
+
+func bestow(ctx context.Context, db sqlx.ExtContext, ord Order) (gift, error) {
+	if ord.Recipient == "" {
+		return gift{}, nil
+	}
+
+	usr, err := user.FetchByEmail(ctx, db, ord.Recipient)
+	if err == nil {
+		if err := UpdateOwner(ctx, db, ord.ID, usr.ID); err != nil {
+			return gift{}, fmt.Errorf("giving order to user[%s]: %w", usr.ID, err)
+		}
+		return gift{}, nil
+	}
+
+	if !errors.Is(err, database.ErrDBNotFound) {
+		return gift{}, fmt.Errorf("fetching recipient by email %s: %w", ord.Recipient, err)
+	}
+
+	text, tok, err := token.GenToken("", giftTTL, token.GiftToken)
+	if err != nil {
+		return gift{}, fmt.Errorf("generating gift token: %w", err)
+	}
+	tok.OrderID = &ord.ID
+
+	if err := token.CreateGift(ctx, db, tok); err != nil {
+		return gift{}, fmt.Errorf("creating gift token: %w", err)
+	}
+
+	return gift{Token: text, Email: ord.Recipient}, nil
+}
+
+func deliver(mailer token.Mailer, bg *background.Background, g gift) {
+	if g.Token == "" {
+		return
+	}
+
+	bg.Add(func() error {
+		if err := mailer.SendGiftToken(g.Token, g.Email); err != nil {
+			return fmt.Errorf("failed to send gift token to %s: %w", g.Email, err)
+		}
+		return nil
+	})
+}
+
+func claim(ctx context.Context, db *sqlx.DB, text string, userID string) (string, error) {
+	hash := sha256.Sum256([]byte(text))
+
+	var orderID string
+	err := database.Transaction(db, func(tx sqlx.ExtContext) error {
+		var err error
+		if orderID, err = ClaimGift(ctx, tx, hash[:], userID); err != nil {
+			return err
+		}
+		return token.DeleteByHash(ctx, tx, hash[:])
+	})
+
+	if err != nil {
+		return "", fmt.Errorf("claiming gift for user[%s]: %w", userID, err)
+	}
+	return orderID, nil
+}
+
+func HandleGiftClaim(db *sqlx.DB, session *scs.SessionManager) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var gc GiftClaim
+		if err := web.Decode(w, r, &gc); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(gc); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		userID, ok := auth.SessionUser(ctx, session)
+		if !ok {
+			session.Put(ctx, giftKey, gc.Token)
+			return web.Respond(ctx, w, nil, http.StatusAccepted)
+		}
+
+		orderID, err := claim(ctx, db, gc.Token, userID)
+		if err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NewError(err, "gift not found or already claimed", http.StatusUnprocessableEntity)
+			}
+			return err
+		}
+
+		ord, err := Fetch(ctx, db, orderID)
+		if err != nil {
+			return fmt.Errorf("fetching claimed order[%s]: %w", orderID, err)
+		}
+
+		ord.Items, err = FetchItems(ctx, db, ord.ID)
+		if err != nil {
+			return fmt.Errorf("fetching items of order[%s]: %w", ord.ID, err)
+		}
+
+		return web.Respond(ctx, w, ord, http.StatusOK)
+	}
+}
+
+// ClaimGifts claims the gift stored in the session by HandleGiftClaim as
+// soon as the wrapped handler logs a user in. A failed claim never fails the
+// login: the gift is kept in the session and claimed on the next one.
+func ClaimGifts(db *sqlx.DB, session *scs.SessionManager) web.Middleware {
+	m := func(handler web.Handler) web.Handler {
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if err := handler(ctx, w, r); err != nil {
+				return err
+			}
+
+			text, ok := session.Get(ctx, giftKey).(string)
+			if !ok {
+				return nil
+			}
+
+			userID, ok := auth.SessionUser(ctx, session)
+			if !ok {
+				return nil
+			}
+
+			if _, err := claim(ctx, db, text, userID); err != nil && !errors.Is(err, database.ErrDBNotFound) {
+				return nil
+			}
+
+			session.Remove(ctx, giftKey)
+			return nil
+		}
+		return h
+	}
+	return m
+}
@@ -2,73 +2,237 @@ package order
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/irsalhamdi/e-commerce-video/api/background"
 	"github.com/irsalhamdi/e-commerce-video/api/web"
 	"github.com/irsalhamdi/e-commerce-video/api/weberr"
-	"github.com/irsalhamdi/e-commerce-video/config"
+	"github.com/irsalhamdi/e-commerce-video/core/bundle"
 	"github.com/irsalhamdi/e-commerce-video/core/cart"
 	"github.com/irsalhamdi/e-commerce-video/core/claims"
+	"github.com/irsalhamdi/e-commerce-video/core/coupon"
 	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/core/subscription"
+	"github.com/irsalhamdi/e-commerce-video/core/token"
+	"github.com/irsalhamdi/e-commerce-video/core/user"
 	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/irsalhamdi/e-commerce-video/money"
+	"github.com/irsalhamdi/e-commerce-video/tax"
 	"github.com/irsalhamdi/e-commerce-video/validate"
 	"github.com/jmoiron/sqlx"
-	"github.com/stripe/stripe-go/v74"
-	stripecl "github.com/stripe/stripe-go/v74/client"
-	"github.com/stripe/stripe-go/v74/webhook"
-
-	"github.com/plutov/paypal/v4"
 )
 
-func checkout(ctx context.Context, db *sqlx.DB, userID string) ([]course.Course, error) {
+func checkout(ctx context.Context, db *sqlx.DB, userID string, cn CheckoutNew, rates tax.Rates) (Purchase, error) {
+	if len(rates) > 0 && cn.Country == "" {
+		err := errors.New("billing country is required")
+		return Purchase{}, weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+	}
+
 	items, err := cart.FetchItems(ctx, db, userID)
 	if err != nil {
-		return nil, fmt.Errorf("fetching cart items: %w", err)
+		return Purchase{}, fmt.Errorf("fetching cart items: %w", err)
 	}
 
-	courses := make([]course.Course, 0, len(items))
+	p := Purchase{
+		UserID:    userID,
+		Currency:  cn.Currency,
+		Recipient: cn.Recipient,
+		Country:   strings.ToUpper(cn.Country),
+		Lines:     make([]Line, 0, len(items)),
+	}
+
+	owned := make(map[string]bool)
+	if cn.Recipient == "" {
+		cs, err := course.FetchByOwner(ctx, db, userID)
+		if err != nil {
+			return Purchase{}, fmt.Errorf("fetching owned courses: %w", err)
+		}
+		for _, c := range cs {
+			owned[c.ID] = true
+		}
+	}
+
+	stale := StaleCart{Courses: []string{}, Bundles: []string{}}
+	var names []string
+
 	for _, it := range items {
+		// Courses bought since they were added are dropped instead of being
+		// charged twice.
+		if owned[it.CourseID] {
+			if err := cart.DeleteItem(ctx, db, userID, it.CourseID); err != nil {
+				return Purchase{}, fmt.Errorf("removing owned course[%s] from cart: %w", it.CourseID, err)
+			}
+			continue
+		}
+
 		c, err := course.Fetch(ctx, db, it.CourseID)
 		if err != nil {
-			return nil, fmt.Errorf("fetching course[%s]: %w", it.CourseID, err)
+			return Purchase{}, fmt.Errorf("fetching course[%s]: %w", it.CourseID, err)
+		}
+
+		amount, ok := c.Prices[cn.Currency]
+		if !ok {
+			err := fmt.Errorf("course %s is not available in %s", c.Name, cn.Currency)
+			return Purchase{}, weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		if snap, ok := it.Prices[cn.Currency]; !ok || snap != amount {
+			stale.Courses = append(stale.Courses, c.ID)
+			names = append(names, c.Name)
+		}
+
+		p.Lines = append(p.Lines, Line{Course: c, Amount: amount})
+	}
+
+	bundles, err := cart.FetchBundles(ctx, db, userID)
+	if err != nil {
+		return Purchase{}, fmt.Errorf("fetching cart bundles: %w", err)
+	}
+
+	for _, cb := range bundles {
+		b, err := bundle.Fetch(ctx, db, cb.BundleID)
+		if err != nil {
+			return Purchase{}, fmt.Errorf("fetching bundle[%s]: %w", cb.BundleID, err)
+		}
+
+		if b.Courses, err = bundle.FetchCourses(ctx, db, b.ID); err != nil {
+			return Purchase{}, fmt.Errorf("fetching courses of bundle[%s]: %w", b.ID, err)
+		}
+
+		for _, c := range b.Courses {
+			if owned[c.ID] {
+				err := fmt.Errorf("bundle %s contains the already owned course %s", b.Name, c.Name)
+				return Purchase{}, weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+			}
+		}
+
+		amounts, err := b.Split(cn.Currency)
+		if err != nil {
+			return Purchase{}, weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		if snap, ok := cb.Prices[cn.Currency]; !ok || snap != b.Prices[cn.Currency] {
+			stale.Bundles = append(stale.Bundles, b.ID)
+			names = append(names, b.Name)
+		}
+
+		for i, c := range b.Courses {
+			p.Lines = append(p.Lines, Line{Course: c, Bundle: &b, Amount: amounts[i]})
+		}
+	}
+
+	if len(names) > 0 {
+		stale.Error = fmt.Sprintf("prices changed since they were added to the cart: %s", strings.Join(names, ", "))
+		err := errors.New(stale.Error)
+		return Purchase{}, weberr.Wrap(&weberr.RequestError{Err: err}, weberr.WithResponse(stale, http.StatusConflict))
+	}
+
+	seen := make(map[string]bool, len(p.Lines))
+	for _, l := range p.Lines {
+		if seen[l.Course.ID] {
+			err := fmt.Errorf("course %s is in the cart more than once", l.Course.Name)
+			return Purchase{}, weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
 		}
+		seen[l.Course.ID] = true
+	}
+
+	crt, err := cart.Fetch(ctx, db, userID)
+	if err != nil && !errors.Is(err, database.ErrDBNotFound) {
+		return Purchase{}, fmt.Errorf("fetching cart: %w", err)
+	}
+
+	if crt.CouponID != nil && len(p.Lines) > 0 {
+		if err := discount(ctx, db, &p, *crt.CouponID); err != nil {
+			return Purchase{}, err
+		}
+	}
+
+	p.applyTax(rates)
+
+	return p, nil
+}
+
+func discount(ctx context.Context, db *sqlx.DB, p *Purchase, couponID string) error {
+	cp, err := coupon.Fetch(ctx, db, couponID)
+	if err != nil {
+		return fmt.Errorf("fetching coupon[%s]: %w", couponID, err)
+	}
+
+	if err := cp.Check(time.Now().UTC()); err != nil {
+		return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+	}
 
-		courses = append(courses, c)
+	ls := make([]coupon.Line, len(p.Lines))
+	for i, l := range p.Lines {
+		ls[i] = coupon.Line{CourseID: l.Course.ID, Amount: l.Amount}
+	}
+
+	ls, err = cp.Discount(p.Currency, ls)
+	if err != nil {
+		return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
 	}
 
-	return courses, nil
+	for i := range p.Lines {
+		p.Lines[i].Amount = ls[i].Amount
+	}
+	p.CouponID = &cp.ID
+
+	return nil
 }
 
-func prepare(ctx context.Context, db *sqlx.DB, userID string, providerID string, courses []course.Course) error {
+func prepare(ctx context.Context, db *sqlx.DB, provider string, providerID string, p Purchase) error {
 	err := database.Transaction(db, func(tx sqlx.ExtContext) error {
 		now := time.Now().UTC()
 		ord := Order{
 			ID:         validate.GenerateID(),
-			UserID:     userID,
+			UserID:     p.UserID,
+			Provider:   provider,
 			ProviderID: providerID,
+			CouponID:   p.CouponID,
+			Recipient:  p.Recipient,
+			Country:    p.Country,
+			TaxRate:    p.TaxRate,
 			Status:     Pending,
 			CreatedAt:  now,
 			UpdatedAt:  now,
 		}
 
+		if p.Recipient == "" {
+			ord.OwnerID = &p.UserID
+		}
+
 		if err := Create(ctx, tx, ord); err != nil {
 			return fmt.Errorf("creating order: %w", err)
 		}
 
-		for _, c := range courses {
+		// The coupon is reserved until the checkout is paid or expires, so
+		// that open checkouts cannot redeem it past its maximum.
+		if p.CouponID != nil {
+			if err := coupon.Redeem(ctx, tx, *p.CouponID); err != nil {
+				return err
+			}
+		}
+
+		for _, l := range p.Lines {
 			it := Item{
 				OrderID:   ord.ID,
-				CourseID:  c.ID,
-				Price:     c.Price,
+				CourseID:  l.Course.ID,
+				Net:       l.Net,
+				Tax:       l.Tax,
+				Amount:    l.Amount,
+				Currency:  p.Currency,
 				CreatedAt: now,
 			}
 
+			if l.Bundle != nil {
+				it.BundleID = &l.Bundle.ID
+			}
+
 			if err := CreateItem(ctx, tx, it); err != nil {
 				return fmt.Errorf("creating item: %w", err)
 			}
@@ -78,217 +242,775 @@ func prepare(ctx context.Context, db *sqlx.DB, userID string, providerID string,
 	})
 
 	if err != nil {
-		return fmt.Errorf("creating the order bound to payment[%s] for user[%s]: %w", providerID, userID, err)
+		return fmt.Errorf("creating the order bound to payment[%s] for user[%s]: %w", providerID, p.UserID, err)
 	}
 	return nil
 }
 
-func fulfill(ctx context.Context, db *sqlx.DB, providerID string) error {
+// fulfill records the payment of a pending order and hands over its courses.
+// An expired order is fulfilled as well, since its payment was taken anyway:
+// it is checked for duplicates like any other. Orders already paid, refunded
+// ones included, are refused with ErrFulfilled.
+func fulfill(ctx context.Context, db sqlx.ExtContext, providerID string, paymentID string) (gift, error) {
 	ord, err := FetchByProviderID(ctx, db, providerID)
 	if err != nil {
-		return fmt.Errorf("fetching the order bound to payment[%s]: %w", providerID, err)
+		return gift{}, fmt.Errorf("fetching the order bound to payment[%s]: %w", providerID, err)
 	}
 
-	err = database.Transaction(db, func(tx sqlx.ExtContext) error {
-		up := StatusUp{
-			ID:        ord.ID,
-			Status:    Success,
-			UpdatedAt: time.Now().UTC(),
+	switch ord.Status {
+	case Pending, Expired:
+	case Success, Refunded:
+		return gift{}, fmt.Errorf("order[%s] with status[%s]: %w", ord.ID, ord.Status, ErrFulfilled)
+	default:
+		return gift{}, fmt.Errorf("order[%s] with status[%s]: %w", ord.ID, ord.Status, ErrNotPending)
+	}
+
+	up := PaymentUp{
+		ID:        ord.ID,
+		From:      ord.Status,
+		Status:    Success,
+		PaymentID: paymentID,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if err = UpdatePayment(ctx, db, up); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return gift{}, fmt.Errorf("order[%s] changed concurrently: %w", ord.ID, ErrNotPending)
 		}
+		return gift{}, fmt.Errorf("updating payment of order[%s]: %w", ord.ID, err)
+	}
 
-		if err = UpdateStatus(ctx, tx, up); err != nil {
-			return fmt.Errorf("updating status: %w", err)
+	review := false
+
+	if ord.OwnerID != nil {
+		dups, err := FetchDuplicates(ctx, db, ord)
+		if err != nil {
+			return gift{}, fmt.Errorf("checking duplicates of order[%s]: %w", ord.ID, err)
+		}
+		review = len(dups) > 0
+	}
+
+	// The coupon of an expired checkout was released: it is redeemed again,
+	// and the order reviewed when the coupon got exhausted in the meantime.
+	if ord.Status == Expired && ord.CouponID != nil {
+		err := coupon.Redeem(ctx, db, *ord.CouponID)
+		switch {
+		case errors.Is(err, coupon.ErrExhausted):
+			review = true
+		case err != nil:
+			return gift{}, fmt.Errorf("redeeming coupon of order[%s]: %w", ord.ID, err)
 		}
+	}
 
-		if err = cart.Delete(ctx, tx, ord.UserID); err != nil {
-			return fmt.Errorf("flushing cart: %w", err)
+	if review {
+		if err = UpdateReview(ctx, db, ord.ID, true); err != nil {
+			return gift{}, fmt.Errorf("flagging order[%s] for review: %w", ord.ID, err)
 		}
+	}
+
+	if ord.Provider != ProviderFree {
+		if _, err = CreateInvoice(ctx, db, ord.ID); err != nil {
+			return gift{}, fmt.Errorf("creating invoice of order[%s]: %w", ord.ID, err)
+		}
+	}
 
+	if err = cart.Delete(ctx, db, ord.UserID); err != nil {
+		return gift{}, fmt.Errorf("flushing cart of order[%s]: %w", ord.ID, err)
+	}
+
+	g, err := bestow(ctx, db, ord)
+	if err != nil {
+		return gift{}, fmt.Errorf("handing over order[%s]: %w", ord.ID, err)
+	}
+
+	return g, nil
+}
+
+func refund(ctx context.Context, db sqlx.ExtContext, ord Order) error {
+	if ord.Status != Success {
+		return fmt.Errorf("order[%s] with status[%s]: %w", ord.ID, ord.Status, ErrNotRefundable)
+	}
+
+	up := StatusUp{
+		ID:        ord.ID,
+		From:      Success,
+		Status:    Refunded,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if err := UpdateStatus(ctx, db, up); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return fmt.Errorf("order[%s] changed concurrently: %w", ord.ID, ErrNotRefundable)
+		}
+		return fmt.Errorf("refunding the order[%s]: %w", ord.ID, err)
+	}
+	return nil
+}
+
+func expire(ctx context.Context, db sqlx.ExtContext, providerID string) error {
+	ord, err := FetchByProviderID(ctx, db, providerID)
+	if err != nil {
+		return fmt.Errorf("fetching the order bound to payment[%s]: %w", providerID, err)
+	}
+
+	if ord.Status != Pending {
 		return nil
-	})
+	}
+
+	up := StatusUp{
+		ID:        ord.ID,
+		From:      Pending,
+		Status:    Expired,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	// The order may have been paid since it was read: it is then left alone,
+	// along with the coupon it still holds.
+	if err := UpdateStatus(ctx, db, up); err != nil {
+		if errors.Is(err, database.ErrDBNotFound) {
+			return nil
+		}
+		return fmt.Errorf("expiring the order[%s]: %w", ord.ID, err)
+	}
+
+	if ord.CouponID != nil {
+		if err := coupon.Release(ctx, db, *ord.CouponID); err != nil {
+			return fmt.Errorf("releasing coupon of order[%s]: %w", ord.ID, err)
+		}
+	}
+	return nil
+}
 
+func approve(ctx context.Context, db *sqlx.DB, prov PaymentProvider, ev Event) (Event, error) {
+	ord, err := FetchByProviderID(ctx, db, ev.ProviderID)
 	if err != nil {
-		return fmt.Errorf("fulfilling the order[%s] bound to payment[%s]: %w", ord.ID, providerID, err)
+		return ev, fmt.Errorf("fetching the order bound to payment[%s]: %w", ev.ProviderID, err)
+	}
+
+	if ord.Status != Pending {
+		return Event{}, nil
+	}
+
+	paymentID, err := prov.Capture(ctx, ev.ProviderID)
+	if err != nil {
+		return ev, err
+	}
+
+	ev.Kind = EventPaid
+	ev.PaymentID = paymentID
+
+	return ev, nil
+}
+
+func process(ctx context.Context, db sqlx.ExtContext, provider string, ev Event) (gift, error) {
+	switch ev.Kind {
+	case EventPaid:
+		g, err := fulfill(ctx, db, ev.ProviderID, ev.PaymentID)
+		if err != nil && !errors.Is(err, ErrFulfilled) {
+			return gift{}, fmt.Errorf("the order was payed but its fulfillment failed: %w", err)
+		}
+		return g, nil
+
+	case EventExpired:
+		if err := expire(ctx, db, ev.ProviderID); err != nil {
+			return gift{}, fmt.Errorf("the checkout expired but the order was not updated: %w", err)
+		}
+
+	case EventRefunded:
+		ord, err := FetchByPaymentID(ctx, db, provider, ev.PaymentID)
+		if err != nil {
+			return gift{}, fmt.Errorf("fetching the order bound to payment[%s]: %w", ev.PaymentID, err)
+		}
+
+		if ord.Status == Refunded {
+			return gift{}, nil
+		}
+
+		if err := refund(ctx, db, ord); err != nil {
+			return gift{}, fmt.Errorf("the payment was refunded but the order was not updated: %w", err)
+		}
+
+	case EventSubscriptionChanged:
+		now := time.Now().UTC()
+		sub := ev.Subscription
+		sub.ID = validate.GenerateID()
+		sub.CreatedAt = now
+		sub.UpdatedAt = now
+
+		if err := subscription.Upsert(ctx, db, sub); err != nil {
+			return gift{}, fmt.Errorf("the subscription changed but it was not updated: %w", err)
+		}
+
+	case EventSubscriptionBilled:
+		sub := ev.Subscription
+		sub.UpdatedAt = time.Now().UTC()
+
+		if err := subscription.UpdateStatus(ctx, db, sub); err != nil {
+			return gift{}, fmt.Errorf("the subscription was billed but it was not updated: %w", err)
+		}
+	}
+
+	return gift{}, nil
+}
+
+// cancelPending cancels the pending checkouts of the user sharing some
+// courses with the purchase, so that they cannot be both paid. A paypal
+// order cannot be canceled on paypal: one being captured right now still
+// gets paid, and is fulfilled late, flagged for review if the courses were
+// bought again in the meantime.
+func cancelPending(ctx context.Context, db *sqlx.DB, provs map[string]PaymentProvider, p Purchase) error {
+	orders, err := FetchAll(ctx, db, Filter{UserID: p.UserID, Status: Pending})
+	if err != nil {
+		return fmt.Errorf("fetching pending orders: %w", err)
+	}
+
+	courses := make(map[string]bool, len(p.Lines))
+	for _, l := range p.Lines {
+		courses[l.Course.ID] = true
+	}
+
+	for _, ord := range orders {
+		if ord.Recipient != p.Recipient {
+			continue
+		}
+
+		items, err := FetchItems(ctx, db, ord.ID)
+		if err != nil {
+			return fmt.Errorf("fetching items of order[%s]: %w", ord.ID, err)
+		}
+
+		overlap := false
+		for _, it := range items {
+			if courses[it.CourseID] {
+				overlap = true
+				break
+			}
+		}
+		if !overlap {
+			continue
+		}
+
+		if prov, ok := provs[ord.Provider]; ok {
+			if err := prov.Cancel(ctx, ord.ProviderID); err != nil {
+				err := fmt.Errorf("canceling order[%s]: %w", ord.ID, err)
+				if errors.Is(err, ErrNotCancelable) {
+					return weberr.NewError(err, "a previous checkout of these courses is being completed", http.StatusConflict)
+				}
+				return err
+			}
+		}
+
+		err = database.Transaction(db, func(tx sqlx.ExtContext) error {
+			return expire(ctx, tx, ord.ProviderID)
+		})
+		if err != nil {
+			return fmt.Errorf("canceling order[%s]: %w", ord.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func ExpireStale(ctx context.Context, db *sqlx.DB, ttl time.Duration) error {
+	before := time.Now().UTC().Add(-ttl)
+	if err := ExpirePending(ctx, db, before); err != nil {
+		return fmt.Errorf("expiring stale orders: %w", err)
 	}
 	return nil
 }
 
-func HandlePaypalCheckout(db *sqlx.DB, pp *paypal.Client) web.Handler {
+func provider(r *http.Request, provs map[string]PaymentProvider) (string, PaymentProvider, error) {
+	name := web.Param(r, "provider")
+
+	prov, ok := provs[name]
+	if !ok {
+		return "", nil, weberr.NotFound(fmt.Errorf("payment provider %s not found", name))
+	}
+
+	return name, prov, nil
+}
+
+func HandleCheckout(db *sqlx.DB, provs map[string]PaymentProvider, rates tax.Rates) web.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		name, prov, err := provider(r, provs)
+		if err != nil {
+			return err
+		}
+
 		clm, err := claims.Get(ctx)
 		if err != nil {
 			return weberr.NotAuthorized(errors.New("user not authenticated"))
 		}
 
-		courses, err := checkout(ctx, db, clm.UserID)
+		var cn CheckoutNew
+		if err := web.Decode(w, r, &cn); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(cn); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		p, err := checkout(ctx, db, clm.UserID, cn, rates)
 		if err != nil {
 			return fmt.Errorf("fetching details of cart items: %w", err)
 		}
 
-		if len(courses) == 0 {
+		if len(p.Lines) == 0 {
 			err := errors.New("no items to checkout")
 			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
 		}
 
-		var tot int
-		items := make([]paypal.Item, 0, len(courses))
-		for _, c := range courses {
-			items = append(items, paypal.Item{
-				Quantity:    "1",
-				Name:        c.Name,
-				Description: c.Description,
+		if p.Total() == 0 {
+			err := errors.New("nothing to pay, use the free checkout")
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
 
-				UnitAmount: &paypal.Money{
-					Currency: "USD",
-					Value:    strconv.Itoa(c.Price),
-				},
-			})
+		if err := cancelPending(ctx, db, provs, p); err != nil {
+			return fmt.Errorf("canceling previous checkouts: %w", err)
+		}
 
-			tot += c.Price
+		providerID, resp, err := prov.Checkout(ctx, p)
+		if err != nil {
+			return fmt.Errorf("creating %s checkout: %w", name, err)
 		}
 
-		units := []paypal.PurchaseUnitRequest{{
-			Items: items,
+		if err := prepare(ctx, db, name, providerID, p); err != nil {
+			err := fmt.Errorf("creating the order on the database: %w", err)
+			if errors.Is(err, coupon.ErrExhausted) {
+				if cerr := prov.Cancel(ctx, providerID); cerr != nil {
+					err = fmt.Errorf("%w: canceling %s checkout: %v", err, name, cerr)
+				}
+				return weberr.NewError(err, coupon.ErrExhausted.Error(), http.StatusConflict)
+			}
+			return err
+		}
 
-			Amount: &paypal.PurchaseUnitAmount{
-				Currency: "USD",
-				Value:    strconv.Itoa(tot),
+		return web.Respond(ctx, w, resp, http.StatusOK)
+	}
+}
 
-				Breakdown: &paypal.PurchaseUnitAmountBreakdown{ItemTotal: &paypal.Money{
-					Currency: "USD",
-					Value:    strconv.Itoa(tot),
-				}},
-			},
-		}}
+func HandleCapture(db *sqlx.DB, provs map[string]PaymentProvider, mailer token.Mailer, bg *background.Background) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		name, prov, err := provider(r, provs)
+		if err != nil {
+			return err
+		}
 
-		app := &paypal.ApplicationContext{
-			// ReturnURL: "/success.html",
-			// CancelURL: "/canceled.html",
+		providerID := web.Param(r, "id")
+
+		ord, err := FetchByProviderID(ctx, db, providerID)
+		if err != nil {
+			err := fmt.Errorf("fetching the order bound to payment[%s]: %w", providerID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
 		}
 
-		ord, err := pp.CreateOrder(ctx, "CAPTURE", units, nil, app)
+		switch ord.Status {
+		case Pending:
+		case Success:
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		default:
+			err := fmt.Errorf("order with status %s cannot be captured", ord.Status)
+			return weberr.NewError(err, err.Error(), http.StatusConflict)
+		}
+
+		paymentID, err := prov.Capture(ctx, providerID)
 		if err != nil {
-			return fmt.Errorf("creating paypal order: %w", err)
+			if errors.Is(err, ErrNotSupported) {
+				return weberr.NotFound(fmt.Errorf("%s orders cannot be captured: %w", name, err))
+			}
+			return fmt.Errorf("capturing %s order[%s]: %w", name, providerID, err)
 		}
 
-		if err := prepare(ctx, db, clm.UserID, ord.ID, courses); err != nil {
-			return fmt.Errorf("creating the order on the database: %w", err)
+		var g gift
+		err = database.Transaction(db, func(tx sqlx.ExtContext) error {
+			g, err = fulfill(ctx, tx, providerID, paymentID)
+			return err
+		})
+		if err != nil && !errors.Is(err, ErrFulfilled) {
+			return fmt.Errorf("the order was payed but its fulfillment failed: %w", err)
 		}
+		deliver(mailer, bg, g)
 
-		return web.Respond(ctx, w, ord, http.StatusOK)
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
 	}
 }
 
-func HandlePaypalCapture(db *sqlx.DB, pp *paypal.Client) web.Handler {
+func HandleWebhook(db *sqlx.DB, provs map[string]PaymentProvider, mailer token.Mailer, bg *background.Background) web.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-		providerID := web.Param(r, "id")
+		name, prov, err := provider(r, provs)
+		if err != nil {
+			return err
+		}
 
-		resp, err := pp.CaptureOrder(ctx, providerID, paypal.CaptureOrderRequest{})
+		ev, err := prov.Webhook(ctx, r)
 		if err != nil {
-			return fmt.Errorf("capturing paypal order[%s]: %w", providerID, err)
+			if errors.Is(err, ErrNotSupported) {
+				return weberr.NotFound(fmt.Errorf("%s does not send events: %w", name, err))
+			}
+			return fmt.Errorf("reading %s event: %w", name, err)
 		}
 
-		if resp.Status != "COMPLETED" {
-			return fmt.Errorf("captured order[%s] with status[%s] different from 'COMPLETED'", providerID, resp.Status)
+		if ev.Kind == EventApproved {
+			if ev, err = approve(ctx, db, prov, ev); err != nil {
+				return fmt.Errorf("capturing %s order[%s]: %w", name, ev.ProviderID, err)
+			}
 		}
 
-		if err := fulfill(ctx, db, providerID); err != nil {
-			return fmt.Errorf("the order was payed but its fulfillment failed: %w", err)
+		if ev.Kind == "" {
+			return web.Respond(ctx, w, nil, http.StatusNoContent)
 		}
 
+		var g gift
+		err = database.Transaction(db, func(tx sqlx.ExtContext) error {
+			if ev.ID != "" {
+				if err := CreateEvent(ctx, tx, name, ev.ID); err != nil {
+					return fmt.Errorf("recording event[%s]: %w", ev.ID, err)
+				}
+			}
+			g, err = process(ctx, tx, name, ev)
+			return err
+		})
+
+		if err != nil {
+			if errors.Is(err, ErrEventProcessed) {
+				return web.Respond(ctx, w, nil, http.StatusNoContent)
+			}
+			return fmt.Errorf("processing %s event[%s]: %w", name, ev.ID, err)
+		}
+		deliver(mailer, bg, g)
+
 		return web.Respond(ctx, w, nil, http.StatusNoContent)
 	}
 }
 
-func HandleStripeCheckout(db *sqlx.DB, strp *stripecl.API, cfg config.Stripe) web.Handler {
+func HandleFreeCheckout(db *sqlx.DB, rates tax.Rates, mailer token.Mailer, bg *background.Background) web.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 		clm, err := claims.Get(ctx)
 		if err != nil {
 			return weberr.NotAuthorized(errors.New("user not authenticated"))
 		}
 
-		courses, err := checkout(ctx, db, clm.UserID)
+		var cn CheckoutNew
+		if err := web.Decode(w, r, &cn); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(cn); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		p, err := checkout(ctx, db, clm.UserID, cn, rates)
 		if err != nil {
 			return fmt.Errorf("fetching details of cart items: %w", err)
 		}
 
-		if len(courses) == 0 {
+		if len(p.Lines) == 0 {
 			err := errors.New("no items to checkout")
 			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
 		}
 
-		li := make([]*stripe.CheckoutSessionLineItemParams, 0, len(courses))
-		for _, c := range courses {
-			li = append(li, &stripe.CheckoutSessionLineItemParams{
-				Quantity: stripe.Int64(1),
+		if p.Total() != 0 {
+			err := errors.New("cart contains items that must be paid")
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
 
-				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-					Currency:    stripe.String("usd"),
-					TaxBehavior: stripe.String("inclusive"),
-					UnitAmount:  stripe.Int64(int64(c.Price) * 100),
+		providerID := validate.GenerateID()
 
-					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-						Name:        stripe.String(c.Name),
-						Description: stripe.String(c.Description),
-					},
-				},
-			})
+		if err := prepare(ctx, db, ProviderFree, providerID, p); err != nil {
+			err := fmt.Errorf("creating the order on the database: %w", err)
+			if errors.Is(err, coupon.ErrExhausted) {
+				return weberr.NewError(err, coupon.ErrExhausted.Error(), http.StatusConflict)
+			}
+			return err
 		}
 
-		params := &stripe.CheckoutSessionParams{
-			SuccessURL: stripe.String(cfg.SuccessURL),
-			CancelURL:  stripe.String(cfg.CancelURL),
-			Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
-			LineItems:  li,
+		var g gift
+		err = database.Transaction(db, func(tx sqlx.ExtContext) error {
+			g, err = fulfill(ctx, tx, providerID, "")
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("fulfilling free order: %w", err)
 		}
+		deliver(mailer, bg, g)
 
-		s, err := strp.CheckoutSessions.New(params)
+		ord, err := FetchByProviderID(ctx, db, providerID)
 		if err != nil {
-			return fmt.Errorf("creating stripe session: %w", err)
+			return fmt.Errorf("fetching free order: %w", err)
 		}
 
-		if err := prepare(ctx, db, clm.UserID, s.ID, courses); err != nil {
-			return fmt.Errorf("creating the order on the database: %w", err)
+		ord.Items, err = FetchItems(ctx, db, ord.ID)
+		if err != nil {
+			return fmt.Errorf("fetching items of order[%s]: %w", ord.ID, err)
 		}
 
-		return web.Respond(ctx, w, s.URL, http.StatusOK)
+		return web.Respond(ctx, w, ord, http.StatusCreated)
 	}
 }
 
-func HandleStripeCapture(db *sqlx.DB, cfg config.Stripe) web.Handler {
+func HandleRefund(db *sqlx.DB, provs map[string]PaymentProvider) web.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-		b, err := ioutil.ReadAll(r.Body)
+		orderID := web.Param(r, "id")
+
+		if err := validate.CheckID(orderID); err != nil {
+			return weberr.BadRequest(fmt.Errorf("passed id is not valid: %w", err))
+		}
+
+		ord, err := Fetch(ctx, db, orderID)
 		if err != nil {
-			return weberr.BadRequest(fmt.Errorf("cannot read the request body: %w", err))
+			err := fmt.Errorf("fetching order[%s]: %w", orderID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		if ord.Status != Success {
+			err := fmt.Errorf("order with status %s cannot be refunded", ord.Status)
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
 		}
 
-		sig := r.Header.Get("Stripe-Signature")
-		if sig == "" {
-			return weberr.BadRequest(errors.New("received stripe event is not signed"))
+		if ord.PaymentID == "" {
+			err := errors.New("order has no captured payment to refund")
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		prov, ok := provs[ord.Provider]
+		if !ok {
+			return fmt.Errorf("order[%s] bound to unknown provider[%s]", ord.ID, ord.Provider)
+		}
+
+		// The order is moved to refunded first, so that two concurrent requests
+		// cannot both refund the payment.
+		if err := refund(ctx, db, ord); err != nil {
+			if errors.Is(err, ErrNotRefundable) {
+				return weberr.NewError(err, ErrNotRefundable.Error(), http.StatusConflict)
+			}
+			return err
 		}
 
-		event, err := webhook.ConstructEvent(b, sig, cfg.WebhookSecret)
+		if err := prov.Refund(ctx, ord.PaymentID); err != nil {
+			err := fmt.Errorf("refunding %s payment[%s]: %w", ord.Provider, ord.PaymentID, err)
+
+			up := StatusUp{
+				ID:        ord.ID,
+				From:      Refunded,
+				Status:    Success,
+				UpdatedAt: time.Now().UTC(),
+			}
+			if uerr := UpdateStatus(ctx, db, up); uerr != nil {
+				return fmt.Errorf("%w, and restoring the order[%s] failed: %v", err, ord.ID, uerr)
+			}
+			return err
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+func HandleList(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		clm, err := claims.Get(ctx)
 		if err != nil {
-			return weberr.BadRequest(fmt.Errorf("cannot construct stripe event: %w", err))
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
 		}
 
-		if event.Type != "checkout.session.completed" {
-			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		orders, err := FetchAllByUser(ctx, db, clm.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching orders of user[%s]: %w", clm.UserID, err)
 		}
 
-		var session stripe.CheckoutSession
-		if err = json.Unmarshal(event.Data.Raw, &session); err != nil {
-			return weberr.BadRequest(fmt.Errorf("unable to decode stripe event: %w", err))
+		for i := range orders {
+			if orders[i].Items, err = FetchItems(ctx, db, orders[i].ID); err != nil {
+				return fmt.Errorf("fetching items of order[%s]: %w", orders[i].ID, err)
+			}
 		}
 
-		if session.Mode != stripe.CheckoutSessionModePayment {
-			return web.Respond(ctx, w, nil, http.StatusNoContent)
+		return web.Respond(ctx, w, orders, http.StatusOK)
+	}
+}
+
+func HandleListAll(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		const layout = "2006-01-02"
+		qs := r.URL.Query()
+
+		var filter Filter
+		if v := qs.Get("userId"); v != "" {
+			if err := validate.CheckID(v); err != nil {
+				return weberr.BadRequest(fmt.Errorf("passed user id is not valid: %w", err))
+			}
+			filter.UserID = v
 		}
 
-		if err := fulfill(ctx, db, session.ID); err != nil {
-			return fmt.Errorf("the order was payed but its fulfillment failed: %w", err)
+		if v := qs.Get("status"); v != "" {
+			switch st := Status(v); st {
+			case Pending, Success, Expired, Refunded:
+				filter.Status = st
+			default:
+				return weberr.BadRequest(fmt.Errorf("passed status %s is not valid", v))
+			}
 		}
 
-		return web.Respond(ctx, w, nil, http.StatusNoContent)
+		if v := qs.Get("review"); v != "" {
+			review, err := strconv.ParseBool(v)
+			if err != nil {
+				return weberr.BadRequest(fmt.Errorf("passed review flag is not valid: %w", err))
+			}
+			filter.Review = review
+		}
+
+		if v := qs.Get("from"); v != "" {
+			from, err := time.Parse(layout, v)
+			if err != nil {
+				return weberr.BadRequest(fmt.Errorf("passed from date is not valid: %w", err))
+			}
+			filter.From = from
+		}
+
+		if v := qs.Get("to"); v != "" {
+			to, err := time.Parse(layout, v)
+			if err != nil {
+				return weberr.BadRequest(fmt.Errorf("passed to date is not valid: %w", err))
+			}
+			filter.To = to.AddDate(0, 0, 1)
+		}
+
+		orders, err := FetchAll(ctx, db, filter)
+		if err != nil {
+			return fmt.Errorf("fetching orders: %w", err)
+		}
+
+		for i := range orders {
+			if orders[i].Items, err = FetchItems(ctx, db, orders[i].ID); err != nil {
+				return fmt.Errorf("fetching items of order[%s]: %w", orders[i].ID, err)
+			}
+		}
+
+		return web.Respond(ctx, w, orders, http.StatusOK)
+	}
+}
+
+func HandleShow(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		orderID := web.Param(r, "id")
+
+		if err := validate.CheckID(orderID); err != nil {
+			return weberr.BadRequest(fmt.Errorf("passed id is not valid: %w", err))
+		}
+
+		ord, err := Fetch(ctx, db, orderID)
+		if err != nil {
+			err := fmt.Errorf("fetching order[%s]: %w", orderID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		if !claims.IsUser(ctx, ord.UserID) && !claims.IsAdmin(ctx) {
+			return weberr.NotAuthorized(errors.New("user trying to fetch the order of another user"))
+		}
+
+		if ord.Items, err = FetchItems(ctx, db, ord.ID); err != nil {
+			return fmt.Errorf("fetching items of order[%s]: %w", ord.ID, err)
+		}
+
+		return web.Respond(ctx, w, ord, http.StatusOK)
+	}
+}
+
+func HandleReceipt(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		orderID := web.Param(r, "id")
+
+		if err := validate.CheckID(orderID); err != nil {
+			return weberr.BadRequest(fmt.Errorf("passed id is not valid: %w", err))
+		}
+
+		ord, err := Fetch(ctx, db, orderID)
+		if err != nil {
+			err := fmt.Errorf("fetching order[%s]: %w", orderID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		if !claims.IsUser(ctx, ord.UserID) && !claims.IsAdmin(ctx) {
+			return weberr.NotAuthorized(errors.New("user trying to fetch the receipt of another user"))
+		}
+
+		if ord.Status != Success {
+			err := fmt.Errorf("order with status %s has no receipt", ord.Status)
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		usr, err := user.Fetch(ctx, db, ord.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching user[%s]: %w", ord.UserID, err)
+		}
+
+		items, err := FetchItems(ctx, db, ord.ID)
+		if err != nil {
+			return fmt.Errorf("fetching items of order[%s]: %w", ord.ID, err)
+		}
+
+		data := receipt{
+			OrderID:  ord.ID,
+			Date:     ord.UpdatedAt.Format("2006-01-02"),
+			Name:     usr.Name,
+			Email:    usr.Email,
+			Country:  ord.Country,
+			TaxRate:  tax.Format(ord.TaxRate),
+			Provider: ord.Provider,
+			Lines:    make([]receiptLine, 0, len(items)),
+		}
+
+		if ord.InvoiceNumber != nil {
+			data.Invoice = fmt.Sprintf("%06d", *ord.InvoiceNumber)
+		}
+
+		var net, tx, total int
+
+		for _, it := range items {
+			c, err := course.Fetch(ctx, db, it.CourseID)
+			if err != nil {
+				return fmt.Errorf("fetching course[%s]: %w", it.CourseID, err)
+			}
+
+			data.Currency = it.Currency
+			data.Lines = append(data.Lines, receiptLine{
+				Name:  c.Name,
+				Net:   money.Format(it.Net, it.Currency),
+				Tax:   money.Format(it.Tax, it.Currency),
+				Price: money.Format(it.Amount, it.Currency),
+			})
+			net += it.Net
+			tx += it.Tax
+			total += it.Amount
+		}
+		data.Net = money.Format(net, data.Currency)
+		data.Tax = money.Format(tx, data.Currency)
+		data.Total = money.Format(total, data.Currency)
+
+		body, err := renderReceipt(data)
+		if err != nil {
+			return fmt.Errorf("rendering receipt of order[%s]: %w", ord.ID, err)
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"receipt-%s.html\"", ord.ID))
+		w.WriteHeader(http.StatusOK)
+
+		if _, err := w.Write(body); err != nil {
+			return fmt.Errorf("cannot write receipt to response writer: %w", err)
+		}
+
+		return nil
 	}
 }
@@ -1,33 +1,180 @@
 package order
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/core/bundle"
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/tax"
+)
+
+var (
+	ErrNotPending = errors.New("order is not pending")
+	ErrFulfilled  = fmt.Errorf("order already fulfilled: %w", ErrNotPending)
+
+	ErrNotRefundable = errors.New("order cannot be refunded")
+
+	ErrEventProcessed = errors.New("event already processed")
+)
 
 type Status string
 
 const (
-	Pending Status = "pending"
-	Success Status = "success"
-	Expired Status = "expired"
+	Pending  Status = "pending"
+	Success  Status = "success"
+	Expired  Status = "expired"
+	Refunded Status = "refunded"
+)
+
+const (
+	ProviderPaypal = "paypal"
+	ProviderStripe = "stripe"
+	ProviderFree   = "free"
 )
 
 type Order struct {
-	ID         string    `json:"id" db:"order_id"`
-	UserID     string    `json:"userId" db:"user_id"`
-	ProviderID string    `json:"providerId" db:"provider_id"`
-	Status     Status    `json:"status" db:"status"`
-	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
+	ID            string    `json:"id" db:"order_id"`
+	UserID        string    `json:"userId" db:"user_id"`
+	Provider      string    `json:"provider" db:"provider"`
+	ProviderID    string    `json:"providerId" db:"provider_id"`
+	PaymentID     string    `json:"-" db:"payment_id"`
+	CouponID      *string   `json:"couponId,omitempty" db:"coupon_id"`
+	Recipient     string    `json:"recipient,omitempty" db:"recipient"`
+	OwnerID       *string   `json:"ownerId,omitempty" db:"owner_id"`
+	Country       string    `json:"country,omitempty" db:"country"`
+	TaxRate       int       `json:"taxRate" db:"tax_rate"`
+	InvoiceNumber *int64    `json:"invoiceNumber,omitempty" db:"invoice_number"`
+	NeedsReview   bool      `json:"needsReview" db:"needs_review"`
+	Status        Status    `json:"status" db:"status"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time `json:"updatedAt" db:"updated_at"`
+	Items         []Item    `json:"items" db:"-"`
+}
+
+type Filter struct {
+	UserID string    `db:"user_id"`
+	Status Status    `db:"status"`
+	Review bool      `db:"-"`
+	From   time.Time `db:"from"`
+	To     time.Time `db:"to"`
 }
 
 type StatusUp struct {
 	ID        string    `db:"order_id"`
+	From      Status    `db:"from"`
 	Status    Status    `db:"status"`
 	UpdatedAt time.Time `db:"updated_at"`
 }
 
+type PaymentUp struct {
+	ID        string    `db:"order_id"`
+	From      Status    `db:"from"`
+	Status    Status    `db:"status"`
+	PaymentID string    `db:"payment_id"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
+
 type Item struct {
 	OrderID   string    `json:"orderId" db:"order_id"`
 	CourseID  string    `json:"courseId" db:"course_id"`
-	Price     int       `json:"price" db:"price"`
+	BundleID  *string   `json:"bundleId,omitempty" db:"bundle_id"`
+	Net       int       `json:"net" db:"net"`
+	Tax       int       `json:"tax" db:"tax"`
+	Amount    int       `json:"amount" db:"amount"`
+	Currency  string    `json:"currency" db:"currency"`
 	CreatedAt time.Time `json:"createdAt" db:"created_at"`
 }
+
+type CheckoutNew struct {
+	Currency  string `json:"currency" validate:"required,iso4217"`
+	Recipient string `json:"recipient" validate:"omitempty,email"`
+	Country   string `json:"country" validate:"omitempty,iso3166_1_alpha2"`
+}
+
+// StaleCart is returned when the price of some cart entries changed since they
+// were added. Adding them to the cart again accepts the new price.
+type StaleCart struct {
+	Error   string   `json:"error"`
+	Courses []string `json:"courses"`
+	Bundles []string `json:"bundles"`
+}
+
+type GiftClaim struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// Line amounts are tax inclusive, Net and Tax are their breakdown.
+type Line struct {
+	Course course.Course
+	Bundle *bundle.Bundle
+	Amount int
+	Net    int
+	Tax    int
+}
+
+type Product struct {
+	Name        string
+	Description string
+	Amount      int
+	Net         int
+	Tax         int
+}
+
+type Purchase struct {
+	UserID    string
+	Currency  string
+	Recipient string
+	Country   string
+	TaxRate   int
+	CouponID  *string
+	Lines     []Line
+}
+
+func (p Purchase) Total() int {
+	var tot int
+	for _, l := range p.Lines {
+		tot += l.Amount
+	}
+	return tot
+}
+
+func (p Purchase) TotalTax() int {
+	var tot int
+	for _, l := range p.Lines {
+		tot += l.Tax
+	}
+	return tot
+}
+
+func (p *Purchase) applyTax(rates tax.Rates) {
+	p.TaxRate = rates.Rate(p.Country)
+	for i, l := range p.Lines {
+		p.Lines[i].Net, p.Lines[i].Tax = tax.Split(l.Amount, p.TaxRate)
+	}
+}
+
+// Products groups the lines as they are sold, so that the courses of a
+// bundle are charged as a single product.
+func (p Purchase) Products() []Product {
+	ps := make([]Product, 0, len(p.Lines))
+	idx := map[string]int{}
+	for _, l := range p.Lines {
+		if l.Bundle == nil {
+			ps = append(ps, Product{Name: l.Course.Name, Description: l.Course.Description, Amount: l.Amount, Net: l.Net, Tax: l.Tax})
+			continue
+		}
+
+		if i, ok := idx[l.Bundle.ID]; ok {
+			ps[i].Amount += l.Amount
+			ps[i].Net += l.Net
+			ps[i].Tax += l.Tax
+			continue
+		}
+
+		idx[l.Bundle.ID] = len(ps)
+		ps = append(ps, Product{Name: l.Bundle.Name, Description: l.Bundle.Description, Amount: l.Amount, Net: l.Net, Tax: l.Tax})
+	}
+	return ps
+}
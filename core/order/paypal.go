@@ -0,0 +1,168 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/money"
+	"github.com/plutov/paypal/v4"
+)
+
+type Paypal struct {
+	client    *paypal.Client
+	webhookID string
+}
+
+func NewPaypal(client *paypal.Client, webhookID string) *Paypal {
+	return &Paypal{client: client, webhookID: webhookID}
+}
+
+func (pp *Paypal) Checkout(ctx context.Context, p Purchase) (string, any, error) {
+	prods := p.Products()
+	items := make([]paypal.Item, 0, len(prods))
+	for _, pr := range prods {
+		items = append(items, paypal.Item{
+			Quantity:    "1",
+			Name:        pr.Name,
+			Description: pr.Description,
+
+			UnitAmount: &paypal.Money{
+				Currency: p.Currency,
+				Value:    money.Format(pr.Net, p.Currency),
+			},
+			Tax: &paypal.Money{
+				Currency: p.Currency,
+				Value:    money.Format(pr.Tax, p.Currency),
+			},
+		})
+	}
+
+	tot := p.Total()
+	tx := p.TotalTax()
+	units := []paypal.PurchaseUnitRequest{{
+		Items: items,
+
+		Amount: &paypal.PurchaseUnitAmount{
+			Currency: p.Currency,
+			Value:    money.Format(tot, p.Currency),
+
+			Breakdown: &paypal.PurchaseUnitAmountBreakdown{
+				ItemTotal: &paypal.Money{
+					Currency: p.Currency,
+					Value:    money.Format(tot-tx, p.Currency),
+				},
+				TaxTotal: &paypal.Money{
+					Currency: p.Currency,
+					Value:    money.Format(tx, p.Currency),
+				},
+			},
+		},
+	}}
+
+	app := &paypal.ApplicationContext{
+		// ReturnURL: "/success.html",
+		// CancelURL: "/canceled.html",
+	}
+
+	ord, err := pp.client.CreateOrder(ctx, "CAPTURE", units, nil, app)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating paypal order: %w", err)
+	}
+
+	return ord.ID, ord, nil
+}
+
+func (pp *Paypal) Capture(ctx context.Context, providerID string) (string, error) {
+	resp, err := pp.client.CaptureOrder(ctx, providerID, paypal.CaptureOrderRequest{})
+	if err != nil {
+		return "", fmt.Errorf("capturing paypal order[%s]: %w", providerID, err)
+	}
+
+	if resp.Status != "COMPLETED" {
+		return "", fmt.Errorf("captured order[%s] with status[%s] different from 'COMPLETED'", providerID, resp.Status)
+	}
+
+	var paymentID string
+	for _, pu := range resp.PurchaseUnits {
+		if pu.Payments != nil && len(pu.Payments.Captures) > 0 {
+			paymentID = pu.Payments.Captures[0].ID
+		}
+	}
+
+	return paymentID, nil
+}
+
+func (pp *Paypal) Webhook(ctx context.Context, r *http.Request) (Event, error) {
+	if pp.webhookID == "" {
+		return Event{}, ErrNotSupported
+	}
+
+	resp, err := pp.client.VerifyWebhookSignature(ctx, r, pp.webhookID)
+	if err != nil {
+		return Event{}, fmt.Errorf("verifying paypal event signature: %w", err)
+	}
+
+	if resp.VerificationStatus != "SUCCESS" {
+		return Event{}, weberr.BadRequest(errors.New("received paypal event has an invalid signature"))
+	}
+
+	var event paypal.AnyEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		return Event{}, weberr.BadRequest(fmt.Errorf("unable to decode paypal event: %w", err))
+	}
+
+	switch event.EventType {
+	case paypal.EventCheckoutOrderApproved:
+		var ord paypal.Order
+		if err := json.Unmarshal(event.Resource, &ord); err != nil {
+			return Event{}, weberr.BadRequest(fmt.Errorf("unable to decode paypal order: %w", err))
+		}
+
+		return Event{ID: event.ID, Kind: EventApproved, ProviderID: ord.ID}, nil
+
+	case paypal.EventPaymentCaptureCompleted:
+		var capture struct {
+			ID                string `json:"id"`
+			Status            string `json:"status"`
+			SupplementaryData struct {
+				RelatedIDs struct {
+					OrderID string `json:"order_id"`
+				} `json:"related_ids"`
+			} `json:"supplementary_data"`
+		}
+		if err := json.Unmarshal(event.Resource, &capture); err != nil {
+			return Event{}, weberr.BadRequest(fmt.Errorf("unable to decode paypal capture: %w", err))
+		}
+
+		if capture.Status != "COMPLETED" {
+			return Event{}, nil
+		}
+
+		return Event{
+			ID:         event.ID,
+			Kind:       EventPaid,
+			ProviderID: capture.SupplementaryData.RelatedIDs.OrderID,
+			PaymentID:  capture.ID,
+		}, nil
+	}
+
+	return Event{}, nil
+}
+
+func (pp *Paypal) Refund(ctx context.Context, paymentID string) error {
+	if _, err := pp.client.RefundCapture(ctx, paymentID, paypal.RefundCaptureRequest{}); err != nil {
+		return fmt.Errorf("refunding paypal capture[%s]: %w", paymentID, err)
+	}
+	return nil
+}
+
+// Cancel has nothing to do on paypal: orders are only captured by us, and
+// never once they stopped being pending. A capture started before the order
+// expired still completes, and the payment is then fulfilled late.
+func (pp *Paypal) Cancel(ctx context.Context, providerID string) error {
+	return nil
+}
@@ -0,0 +1,47 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/irsalhamdi/e-commerce-video/core/subscription"
+)
+
+var (
+	ErrNotSupported  = errors.New("operation not supported by the payment provider")
+	ErrNotCancelable = errors.New("checkout can no longer be canceled")
+)
+
+type EventKind string
+
+const (
+	EventApproved EventKind = "approved"
+	EventPaid     EventKind = "paid"
+	EventExpired  EventKind = "expired"
+	EventRefunded EventKind = "refunded"
+
+	EventSubscriptionChanged EventKind = "subscription_changed"
+	EventSubscriptionBilled  EventKind = "subscription_billed"
+)
+
+// Event is a payment notification received from a provider. An event with
+// an empty kind does not concern orders and is acknowledged without action.
+// The ID is used to acknowledge redelivered events without processing them
+// twice. Subscription events carry the subscription state instead of an
+// order.
+type Event struct {
+	ID           string
+	Kind         EventKind
+	ProviderID   string
+	PaymentID    string
+	Subscription subscription.Subscription
+}
+
+type PaymentProvider interface {
+	Checkout(ctx context.Context, p Purchase) (providerID string, resp any, err error)
+	Capture(ctx context.Context, providerID string) (paymentID string, err error)
+	Webhook(ctx context.Context, r *http.Request) (Event, error)
+	Refund(ctx context.Context, paymentID string) error
+	Cancel(ctx context.Context, providerID string) error
+}
@@ -0,0 +1,48 @@
+package order
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+)
+
+//go:embed templates
+var templates embed.FS
+
+type receiptLine struct {
+	Name  string
+	Net   string
+	Tax   string
+	Price string
+}
+
+type receipt struct {
+	OrderID  string
+	Invoice  string
+	Date     string
+	Name     string
+	Email    string
+	Country  string
+	TaxRate  string
+	Provider string
+	Currency string
+	Lines    []receiptLine
+	Net      string
+	Tax      string
+	Total    string
+}
+
+func renderReceipt(data receipt) ([]byte, error) {
+	t, err := template.New("receipt").ParseFS(templates, "templates/receipt.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("parsing receipt template: %w", err)
+	}
+
+	var body bytes.Buffer
+	if err := t.ExecuteTemplate(&body, "html", data); err != nil {
+		return nil, fmt.Errorf("executing template: %w", err)
+	}
+
+	return body.Bytes(), nil
+}
@@ -2,8 +2,12 @@ package order
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/irsalhamdi/e-commerce-video/core/token"
 	"github.com/irsalhamdi/e-commerce-video/database"
 	"github.com/jmoiron/sqlx"
 )
@@ -11,9 +15,9 @@ import (
 func Create(ctx context.Context, db sqlx.ExtContext, order Order) error {
 	const q = `
 	INSERT INTO orders
-		(order_id, user_id, provider_id, status, created_at, updated_at)
+		(order_id, user_id, provider, provider_id, payment_id, coupon_id, recipient, owner_id, country, tax_rate, status, created_at, updated_at)
 	VALUES
-		(:order_id, :user_id, :provider_id, :status, :created_at, :updated_at)`
+		(:order_id, :user_id, :provider, :provider_id, :payment_id, :coupon_id, :recipient, :owner_id, :country, :tax_rate, :status, :created_at, :updated_at)`
 
 	if err := database.NamedExecContext(ctx, db, q, order); err != nil {
 		return fmt.Errorf("inserting order: %w", err)
@@ -22,6 +26,8 @@ func Create(ctx context.Context, db sqlx.ExtContext, order Order) error {
 	return nil
 }
 
+// UpdateStatus moves the order from the status From. It returns
+// database.ErrDBNotFound when the order was no longer in that status.
 func UpdateStatus(ctx context.Context, db sqlx.ExtContext, up StatusUp) error {
 	const q = `
 	UPDATE orders
@@ -29,15 +35,263 @@ func UpdateStatus(ctx context.Context, db sqlx.ExtContext, up StatusUp) error {
 		status = :status,
 		updated_at = :updated_at
 	WHERE
-		order_id = :order_id`
+		order_id = :order_id AND
+		status = :from
+	RETURNING order_id`
+
+	v := struct {
+		ID string `db:"order_id"`
+	}{}
 
-	if err := database.NamedExecContext(ctx, db, q, up); err != nil {
+	if err := database.NamedQueryStruct(ctx, db, q, up, &v); err != nil {
 		return fmt.Errorf("updating state of order[%s]: %w", up.ID, err)
 	}
 
 	return nil
 }
 
+func UpdatePayment(ctx context.Context, db sqlx.ExtContext, up PaymentUp) error {
+	const q = `
+	UPDATE orders
+	SET
+		status = :status,
+		payment_id = :payment_id,
+		updated_at = :updated_at
+	WHERE
+		order_id = :order_id AND
+		status = :from
+	RETURNING order_id`
+
+	v := struct {
+		ID string `db:"order_id"`
+	}{}
+
+	if err := database.NamedQueryStruct(ctx, db, q, up, &v); err != nil {
+		return fmt.Errorf("updating payment of order[%s]: %w", up.ID, err)
+	}
+
+	return nil
+}
+
+func UpdateReview(ctx context.Context, db sqlx.ExtContext, id string, review bool) error {
+	in := struct {
+		ID     string `db:"order_id"`
+		Review bool   `db:"needs_review"`
+	}{
+		ID:     id,
+		Review: review,
+	}
+
+	const q = `
+	UPDATE orders
+	SET
+		needs_review = :needs_review
+	WHERE
+		order_id = :order_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("updating review of order[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+func UpdateOwner(ctx context.Context, db sqlx.ExtContext, id string, ownerID string) error {
+	in := struct {
+		ID        string    `db:"order_id"`
+		OwnerID   string    `db:"owner_id"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}{
+		ID:        id,
+		OwnerID:   ownerID,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	const q = `
+	UPDATE orders
+	SET
+		owner_id = :owner_id,
+		updated_at = :updated_at
+	WHERE
+		order_id = :order_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("updating owner of order[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+func ClaimGift(ctx context.Context, db sqlx.ExtContext, hash []byte, ownerID string) (string, error) {
+	in := struct {
+		Hash      []byte    `db:"hash"`
+		Scope     string    `db:"scope"`
+		OwnerID   string    `db:"owner_id"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}{
+		Hash:      hash,
+		Scope:     token.GiftToken,
+		OwnerID:   ownerID,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	const q = `
+	UPDATE orders AS o
+	SET
+		owner_id = :owner_id,
+		updated_at = :updated_at
+	FROM
+		tokens AS t
+	WHERE
+		t.order_id = o.order_id AND
+		t.hash = :hash AND
+		t.scope = :scope AND
+		t.expiry > :updated_at AND
+		o.owner_id IS NULL
+	RETURNING o.order_id`
+
+	v := struct {
+		ID string `db:"order_id"`
+	}{}
+
+	if err := database.NamedQueryStruct(ctx, db, q, in, &v); err != nil {
+		return "", fmt.Errorf("claiming gift: %w", err)
+	}
+
+	return v.ID, nil
+}
+
+func ExpirePending(ctx context.Context, db sqlx.ExtContext, before time.Time) error {
+	in := struct {
+		Pending   Status    `db:"pending"`
+		Expired   Status    `db:"expired"`
+		Before    time.Time `db:"before"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}{
+		Pending:   Pending,
+		Expired:   Expired,
+		Before:    before,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	// The coupons reserved by the expired orders are released as well.
+	const q = `
+	WITH expired AS (
+		UPDATE orders
+		SET
+			status = :expired,
+			updated_at = :updated_at
+		WHERE
+			status = :pending AND
+			created_at < :before
+		RETURNING
+			coupon_id
+	)
+	UPDATE coupons c
+	SET
+		redemptions = GREATEST(c.redemptions - e.n, 0)
+	FROM
+		(SELECT coupon_id, COUNT(*) AS n FROM expired WHERE coupon_id IS NOT NULL GROUP BY coupon_id) e
+	WHERE
+		c.coupon_id = e.coupon_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("expiring orders pending since before %s: %w", before, err)
+	}
+
+	return nil
+}
+
+func Fetch(ctx context.Context, db sqlx.ExtContext, id string) (Order, error) {
+	in := struct {
+		ID string `db:"order_id"`
+	}{
+		ID: id,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		orders
+	WHERE
+		order_id = :order_id`
+
+	var order Order
+	if err := database.NamedQueryStruct(ctx, db, q, in, &order); err != nil {
+		return Order{}, fmt.Errorf("selecting order[%s]: %w", id, err)
+	}
+
+	return order, nil
+}
+
+func FetchAllByUser(ctx context.Context, db sqlx.ExtContext, userID string) ([]Order, error) {
+	in := struct {
+		UserID string `db:"user_id"`
+	}{
+		UserID: userID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		orders
+	WHERE
+		user_id = :user_id
+	ORDER BY
+		created_at DESC`
+
+	orders := []Order{}
+	if err := database.NamedQuerySlice(ctx, db, q, in, &orders); err != nil {
+		return nil, fmt.Errorf("selecting orders of user[%s]: %w", userID, err)
+	}
+
+	return orders, nil
+}
+
+func FetchAll(ctx context.Context, db sqlx.ExtContext, filter Filter) ([]Order, error) {
+	var where []string
+	if filter.UserID != "" {
+		where = append(where, "user_id = :user_id")
+	}
+	if filter.Status != "" {
+		where = append(where, "status = :status")
+	}
+	if filter.Review {
+		where = append(where, "needs_review")
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "created_at >= :from")
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "created_at < :to")
+	}
+
+	q := `
+	SELECT
+		*
+	FROM
+		orders`
+
+	if len(where) > 0 {
+		q += `
+	WHERE
+		` + strings.Join(where, " AND\n\t\t")
+	}
+
+	q += `
+	ORDER BY
+		created_at DESC`
+
+	orders := []Order{}
+	if err := database.NamedQuerySlice(ctx, db, q, filter, &orders); err != nil {
+		return nil, fmt.Errorf("selecting orders: %w", err)
+	}
+
+	return orders, nil
+}
+
 func FetchByProviderID(ctx context.Context, db sqlx.ExtContext, provID string) (Order, error) {
 	in := struct {
 		ProviderID string `db:"provider_id"`
@@ -61,12 +315,119 @@ func FetchByProviderID(ctx context.Context, db sqlx.ExtContext, provID string) (
 	return order, nil
 }
 
+func FetchByPaymentID(ctx context.Context, db sqlx.ExtContext, provider string, paymentID string) (Order, error) {
+	in := struct {
+		Provider  string `db:"provider"`
+		PaymentID string `db:"payment_id"`
+	}{
+		Provider:  provider,
+		PaymentID: paymentID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		orders
+	WHERE
+		provider = :provider AND
+		payment_id = :payment_id`
+
+	var order Order
+	if err := database.NamedQueryStruct(ctx, db, q, in, &order); err != nil {
+		return Order{}, fmt.Errorf("selecting order by payment_id[%s]: %w", paymentID, err)
+	}
+
+	return order, nil
+}
+
+// CreateInvoice assigns the next invoice number to the order. The counter row
+// stays locked until the transaction ends, so numbers have no gaps.
+func CreateInvoice(ctx context.Context, db sqlx.ExtContext, orderID string) (int64, error) {
+	in := struct {
+		ID string `db:"order_id"`
+	}{
+		ID: orderID,
+	}
+
+	const q = `
+	WITH n AS (
+		UPDATE invoice_counter
+		SET
+			last = last + 1
+		RETURNING last
+	)
+	UPDATE orders
+	SET
+		invoice_number = n.last
+	FROM
+		n
+	WHERE
+		order_id = :order_id
+	RETURNING invoice_number`
+
+	v := struct {
+		Number int64 `db:"invoice_number"`
+	}{}
+
+	if err := database.NamedQueryStruct(ctx, db, q, in, &v); err != nil {
+		return 0, fmt.Errorf("numbering invoice of order[%s]: %w", orderID, err)
+	}
+
+	return v.Number, nil
+}
+
+// FetchDuplicates returns the courses of the order that its owner already
+// bought with another successful order.
+func FetchDuplicates(ctx context.Context, db sqlx.ExtContext, ord Order) ([]string, error) {
+	in := struct {
+		ID      string  `db:"order_id"`
+		OwnerID *string `db:"owner_id"`
+		Success Status  `db:"success"`
+	}{
+		ID:      ord.ID,
+		OwnerID: ord.OwnerID,
+		Success: Success,
+	}
+
+	const q = `
+	SELECT
+		DISTINCT i.course_id
+	FROM
+		order_items AS i
+	INNER JOIN
+		order_items AS oi ON oi.course_id = i.course_id
+	INNER JOIN
+		orders AS o ON o.order_id = oi.order_id
+	WHERE
+		i.order_id = :order_id AND
+		o.order_id <> :order_id AND
+		o.owner_id = :owner_id AND
+		o.status = :success
+	ORDER BY
+		i.course_id`
+
+	var rows []struct {
+		CourseID string `db:"course_id"`
+	}
+	if err := database.NamedQuerySlice(ctx, db, q, in, &rows); err != nil {
+		return nil, fmt.Errorf("selecting duplicated courses of order[%s]: %w", ord.ID, err)
+	}
+
+	ids := make([]string, len(rows))
+	for i, r := range rows {
+		ids[i] = r.CourseID
+	}
+
+	return ids, nil
+}
+
 func CreateItem(ctx context.Context, db sqlx.ExtContext, item Item) error {
 	const q = `
 	INSERT INTO order_items
-		(order_id, course_id, price, created_at)
+		(order_id, course_id, bundle_id, net, tax, amount, currency, created_at)
 	VALUES
-	(:order_id, :course_id, :price, :created_at)`
+	(:order_id, :course_id, :bundle_id, :net, :tax, :amount, :currency, :created_at)`
 
 	if err := database.NamedExecContext(ctx, db, q, item); err != nil {
 		return fmt.Errorf("inserting order item: %w", err)
@@ -74,3 +435,55 @@ func CreateItem(ctx context.Context, db sqlx.ExtContext, item Item) error {
 
 	return nil
 }
+
+func FetchItems(ctx context.Context, db sqlx.ExtContext, orderID string) ([]Item, error) {
+	in := struct {
+		OrderID string `db:"order_id"`
+	}{
+		OrderID: orderID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		order_items
+	WHERE
+		order_id = :order_id
+	ORDER BY
+		course_id`
+
+	items := []Item{}
+	if err := database.NamedQuerySlice(ctx, db, q, in, &items); err != nil {
+		return nil, fmt.Errorf("selecting items of order[%s]: %w", orderID, err)
+	}
+
+	return items, nil
+}
+
+func CreateEvent(ctx context.Context, db sqlx.ExtContext, provider string, eventID string) error {
+	in := struct {
+		Provider  string    `db:"provider"`
+		EventID   string    `db:"event_id"`
+		CreatedAt time.Time `db:"created_at"`
+	}{
+		Provider:  provider,
+		EventID:   eventID,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	const q = `
+	INSERT INTO processed_events
+		(provider, event_id, created_at)
+	VALUES
+		(:provider, :event_id, :created_at)`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		if errors.Is(err, database.ErrDBDuplicatedEntry) {
+			return fmt.Errorf("event[%s]: %w", eventID, ErrEventProcessed)
+		}
+		return fmt.Errorf("inserting processed event[%s]: %w", eventID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/config"
+	"github.com/irsalhamdi/e-commerce-video/core/subscription"
+	"github.com/irsalhamdi/e-commerce-video/tax"
+	"github.com/stripe/stripe-go/v74"
+	stripecl "github.com/stripe/stripe-go/v74/client"
+	"github.com/stripe/stripe-go/v74/webhook"
+)
+
+type Stripe struct {
+	client *stripecl.API
+	cfg    config.Stripe
+}
+
+func NewStripe(client *stripecl.API, cfg config.Stripe) *Stripe {
+	return &Stripe{client: client, cfg: cfg}
+}
+
+func (s *Stripe) Checkout(ctx context.Context, p Purchase) (string, any, error) {
+	prods := p.Products()
+	li := make([]*stripe.CheckoutSessionLineItemParams, 0, len(prods))
+	for _, pr := range prods {
+		li = append(li, &stripe.CheckoutSessionLineItemParams{
+			Quantity: stripe.Int64(1),
+
+			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+				Currency:    stripe.String(strings.ToLower(p.Currency)),
+				TaxBehavior: stripe.String("exclusive"),
+				UnitAmount:  stripe.Int64(int64(pr.Net)),
+
+				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+					Name:        stripe.String(pr.Name),
+					Description: stripe.String(pr.Description),
+				},
+			},
+		})
+	}
+
+	if tx := p.TotalTax(); tx > 0 {
+		li = append(li, &stripe.CheckoutSessionLineItemParams{
+			Quantity: stripe.Int64(1),
+
+			PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+				Currency:   stripe.String(strings.ToLower(p.Currency)),
+				UnitAmount: stripe.Int64(int64(tx)),
+
+				ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+					Name: stripe.String(fmt.Sprintf("VAT %s (%s)", strings.ToUpper(p.Country), tax.Format(p.TaxRate))),
+				},
+			},
+		})
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		SuccessURL: stripe.String(s.cfg.SuccessURL),
+		CancelURL:  stripe.String(s.cfg.CancelURL),
+		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
+		LineItems:  li,
+	}
+	params.Context = ctx
+
+	sess, err := s.client.CheckoutSessions.New(params)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating stripe session: %w", err)
+	}
+
+	return sess.ID, sess.URL, nil
+}
+
+func (s *Stripe) Subscribe(ctx context.Context, userID string, plan subscription.Plan) (string, any, error) {
+	var price string
+	switch plan {
+	case subscription.Monthly:
+		price = s.cfg.MonthlyPriceID
+	case subscription.Yearly:
+		price = s.cfg.YearlyPriceID
+	}
+
+	if price == "" {
+		return "", nil, fmt.Errorf("stripe %s plan: %w", plan, subscription.ErrPlanUnavailable)
+	}
+
+	params := &stripe.CheckoutSessionParams{
+		SuccessURL:        stripe.String(s.cfg.SuccessURL),
+		CancelURL:         stripe.String(s.cfg.CancelURL),
+		Mode:              stripe.String(string(stripe.CheckoutSessionModeSubscription)),
+		ClientReferenceID: stripe.String(userID),
+		LineItems: []*stripe.CheckoutSessionLineItemParams{{
+			Price:    stripe.String(price),
+			Quantity: stripe.Int64(1),
+		}},
+		SubscriptionData: &stripe.CheckoutSessionSubscriptionDataParams{
+			Metadata: map[string]string{"user_id": userID},
+		},
+	}
+	params.Context = ctx
+
+	sess, err := s.client.CheckoutSessions.New(params)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating stripe subscription session: %w", err)
+	}
+
+	return sess.ID, sess.URL, nil
+}
+
+func (s *Stripe) Capture(ctx context.Context, providerID string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (s *Stripe) Webhook(ctx context.Context, r *http.Request) (Event, error) {
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return Event{}, weberr.BadRequest(fmt.Errorf("cannot read the request body: %w", err))
+	}
+
+	sig := r.Header.Get("Stripe-Signature")
+	if sig == "" {
+		return Event{}, weberr.BadRequest(errors.New("received stripe event is not signed"))
+	}
+
+	event, err := webhook.ConstructEvent(b, sig, s.cfg.WebhookSecret)
+	if err != nil {
+		return Event{}, weberr.BadRequest(fmt.Errorf("cannot construct stripe event: %w", err))
+	}
+
+	switch event.Type {
+	case "checkout.session.completed", "checkout.session.expired":
+		var session stripe.CheckoutSession
+		if err = json.Unmarshal(event.Data.Raw, &session); err != nil {
+			return Event{}, weberr.BadRequest(fmt.Errorf("unable to decode stripe event: %w", err))
+		}
+
+		// Subscriptions are tracked through their own events.
+		if session.Mode != stripe.CheckoutSessionModePayment {
+			return Event{}, nil
+		}
+
+		if event.Type == "checkout.session.expired" {
+			return Event{ID: event.ID, Kind: EventExpired, ProviderID: session.ID}, nil
+		}
+
+		var paymentID string
+		if session.PaymentIntent != nil {
+			paymentID = session.PaymentIntent.ID
+		}
+
+		return Event{ID: event.ID, Kind: EventPaid, ProviderID: session.ID, PaymentID: paymentID}, nil
+
+	case "charge.refunded":
+		var charge stripe.Charge
+		if err = json.Unmarshal(event.Data.Raw, &charge); err != nil {
+			return Event{}, weberr.BadRequest(fmt.Errorf("unable to decode stripe event: %w", err))
+		}
+
+		if !charge.Refunded || charge.PaymentIntent == nil {
+			return Event{}, nil
+		}
+
+		return Event{ID: event.ID, Kind: EventRefunded, PaymentID: charge.PaymentIntent.ID}, nil
+
+	case "customer.subscription.created", "customer.subscription.updated", "customer.subscription.deleted":
+		var sub stripe.Subscription
+		if err = json.Unmarshal(event.Data.Raw, &sub); err != nil {
+			return Event{}, weberr.BadRequest(fmt.Errorf("unable to decode stripe event: %w", err))
+		}
+
+		userID := sub.Metadata["user_id"]
+		if userID == "" {
+			return Event{}, nil
+		}
+
+		plan := subscription.Monthly
+		if sub.Items != nil && len(sub.Items.Data) > 0 {
+			pr := sub.Items.Data[0].Price
+			if pr != nil && pr.Recurring != nil && pr.Recurring.Interval == stripe.PriceRecurringIntervalYear {
+				plan = subscription.Yearly
+			}
+		}
+
+		return Event{ID: event.ID, Kind: EventSubscriptionChanged, Subscription: subscription.Subscription{
+			UserID:     userID,
+			ProviderID: sub.ID,
+			Plan:       plan,
+			Status:     subscription.Status(sub.Status),
+			PeriodEnd:  time.Unix(sub.CurrentPeriodEnd, 0).UTC(),
+		}}, nil
+
+	case "invoice.paid", "invoice.payment_failed":
+		var inv stripe.Invoice
+		if err = json.Unmarshal(event.Data.Raw, &inv); err != nil {
+			return Event{}, weberr.BadRequest(fmt.Errorf("unable to decode stripe event: %w", err))
+		}
+
+		if inv.Subscription == nil {
+			return Event{}, nil
+		}
+
+		sub := subscription.Subscription{ProviderID: inv.Subscription.ID, Status: subscription.PastDue}
+		if event.Type == "invoice.paid" {
+			sub.Status = subscription.Active
+			if inv.Lines != nil {
+				for _, l := range inv.Lines.Data {
+					if l.Period != nil && time.Unix(l.Period.End, 0).After(sub.PeriodEnd) {
+						sub.PeriodEnd = time.Unix(l.Period.End, 0).UTC()
+					}
+				}
+			}
+		}
+
+		return Event{ID: event.ID, Kind: EventSubscriptionBilled, Subscription: sub}, nil
+	}
+
+	return Event{}, nil
+}
+
+func (s *Stripe) Refund(ctx context.Context, paymentID string) error {
+	params := &stripe.RefundParams{PaymentIntent: stripe.String(paymentID)}
+	params.Context = ctx
+
+	if _, err := s.client.Refunds.New(params); err != nil {
+		return fmt.Errorf("refunding stripe payment[%s]: %w", paymentID, err)
+	}
+	return nil
+}
+
+func (s *Stripe) Cancel(ctx context.Context, providerID string) error {
+	params := &stripe.CheckoutSessionExpireParams{}
+	params.Context = ctx
+
+	if _, err := s.client.CheckoutSessions.Expire(providerID, params); err != nil {
+		var serr *stripe.Error
+		if errors.As(err, &serr) && serr.HTTPStatusCode == http.StatusBadRequest {
+			return fmt.Errorf("expiring stripe session[%s]: %w", providerID, ErrNotCancelable)
+		}
+		return fmt.Errorf("expiring stripe session[%s]: %w", providerID, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/api/web"
+	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/core/order"
+	"github.com/jmoiron/sqlx"
+)
+
+func HandleRevenue(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		group := Group(r.URL.Query().Get("group"))
+		if group == "" {
+			group = ByCourse
+		}
+		if _, ok := groupKeys[group]; !ok {
+			return weberr.BadRequest(fmt.Errorf("passed group %s is not valid", group))
+		}
+
+		filter, err := parseFilter(r)
+		if err != nil {
+			return err
+		}
+
+		// Unless explicitly requested, revenue only accounts for paid orders.
+		if filter.Status == "" && group != ByStatus {
+			filter.Status = order.Success
+		}
+
+		rs, err := FetchRevenue(ctx, db, group, filter)
+		if err != nil {
+			return fmt.Errorf("fetching revenue: %w", err)
+		}
+
+		if r.URL.Query().Get("format") != "csv" {
+			return web.Respond(ctx, w, rs, http.StatusOK)
+		}
+
+		records := make([][]string, 0, len(rs)+1)
+		records = append(records, revenueHeader)
+		for _, rv := range rs {
+			records = append(records, rv.record())
+		}
+
+		return respondCSV(w, fmt.Sprintf("revenue-%s.csv", group), records)
+	}
+}
+
+func HandleConversion(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		filter, err := parseFilter(r)
+		if err != nil {
+			return err
+		}
+
+		cs, err := FetchConversion(ctx, db, filter)
+		if err != nil {
+			return fmt.Errorf("fetching conversion: %w", err)
+		}
+
+		total := Conversion{Provider: "all"}
+		for i := range cs {
+			cs[i].Rate = rate(cs[i].Succeeded, cs[i].Orders)
+			total.Orders += cs[i].Orders
+			total.Pending += cs[i].Pending
+			total.Succeeded += cs[i].Succeeded
+			total.Expired += cs[i].Expired
+			total.Refunded += cs[i].Refunded
+		}
+		total.Rate = rate(total.Succeeded, total.Orders)
+		cs = append(cs, total)
+
+		if r.URL.Query().Get("format") != "csv" {
+			return web.Respond(ctx, w, cs, http.StatusOK)
+		}
+
+		records := make([][]string, 0, len(cs)+1)
+		records = append(records, conversionHeader)
+		for _, c := range cs {
+			records = append(records, c.record())
+		}
+
+		return respondCSV(w, "conversion.csv", records)
+	}
+}
+
+func parseFilter(r *http.Request) (Filter, error) {
+	const layout = "2006-01-02"
+	qs := r.URL.Query()
+
+	var filter Filter
+	if v := qs.Get("status"); v != "" {
+		switch st := order.Status(v); st {
+		case order.Pending, order.Success, order.Expired, order.Refunded:
+			filter.Status = st
+		default:
+			return Filter{}, weberr.BadRequest(fmt.Errorf("passed status %s is not valid", v))
+		}
+	}
+
+	if v := qs.Get("from"); v != "" {
+		from, err := time.Parse(layout, v)
+		if err != nil {
+			return Filter{}, weberr.BadRequest(fmt.Errorf("passed from date is not valid: %w", err))
+		}
+		filter.From = from
+	}
+
+	if v := qs.Get("to"); v != "" {
+		to, err := time.Parse(layout, v)
+		if err != nil {
+			return Filter{}, weberr.BadRequest(fmt.Errorf("passed to date is not valid: %w", err))
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	return filter, nil
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+func respondCSV(w http.ResponseWriter, filename string, records [][]string) error {
+	var buf bytes.Buffer
+	if err := csv.NewWriter(&buf).WriteAll(records); err != nil {
+		return fmt.Errorf("encoding csv: %w", err)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("cannot write csv to response writer: %w", err)
+	}
+
+	return nil
+}
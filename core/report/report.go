@@ -0,0 +1,77 @@
+package report
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/core/order"
+)
+
+type Group string
+
+const (
+	ByCourse   Group = "course"
+	ByDay      Group = "day"
+	ByWeek     Group = "week"
+	ByMonth    Group = "month"
+	ByProvider Group = "provider"
+	ByStatus   Group = "status"
+)
+
+type Filter struct {
+	Status order.Status `db:"status"`
+	From   time.Time    `db:"from"`
+	To     time.Time    `db:"to"`
+}
+
+// Revenue aggregates the order items falling in a group. Amounts are kept
+// apart per currency since they cannot be summed together.
+type Revenue struct {
+	Key      string `json:"key" db:"key"`
+	Label    string `json:"label" db:"label"`
+	Currency string `json:"currency" db:"currency"`
+	Orders   int    `json:"orders" db:"orders"`
+	Items    int    `json:"items" db:"items"`
+	Net      int    `json:"net" db:"net"`
+	Tax      int    `json:"tax" db:"tax"`
+	Gross    int    `json:"gross" db:"gross"`
+}
+
+type Conversion struct {
+	Provider  string  `json:"provider" db:"provider"`
+	Orders    int     `json:"orders" db:"orders"`
+	Pending   int     `json:"pending" db:"pending"`
+	Succeeded int     `json:"succeeded" db:"succeeded"`
+	Expired   int     `json:"expired" db:"expired"`
+	Refunded  int     `json:"refunded" db:"refunded"`
+	Rate      float64 `json:"rate" db:"-"`
+}
+
+var revenueHeader = []string{"key", "label", "currency", "orders", "items", "net", "tax", "gross"}
+
+func (r Revenue) record() []string {
+	return []string{
+		r.Key,
+		r.Label,
+		r.Currency,
+		strconv.Itoa(r.Orders),
+		strconv.Itoa(r.Items),
+		strconv.Itoa(r.Net),
+		strconv.Itoa(r.Tax),
+		strconv.Itoa(r.Gross),
+	}
+}
+
+var conversionHeader = []string{"provider", "orders", "pending", "succeeded", "expired", "refunded", "rate"}
+
+func (c Conversion) record() []string {
+	return []string{
+		c.Provider,
+		strconv.Itoa(c.Orders),
+		strconv.Itoa(c.Pending),
+		strconv.Itoa(c.Succeeded),
+		strconv.Itoa(c.Expired),
+		strconv.Itoa(c.Refunded),
+		strconv.FormatFloat(c.Rate, 'f', 4, 64),
+	}
+}
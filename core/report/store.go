@@ -0,0 +1,126 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/irsalhamdi/e-commerce-video/core/order"
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/jmoiron/sqlx"
+)
+
+var groupKeys = map[Group][2]string{
+	ByCourse:   {"CAST(i.course_id AS TEXT)", "c.name"},
+	ByDay:      {"TO_CHAR(DATE_TRUNC('day', o.created_at), 'YYYY-MM-DD')", "TO_CHAR(DATE_TRUNC('day', o.created_at), 'Dy DD Mon YYYY')"},
+	ByWeek:     {"TO_CHAR(DATE_TRUNC('week', o.created_at), 'YYYY-MM-DD')", "TO_CHAR(DATE_TRUNC('week', o.created_at), 'IYYY \"W\"IW')"},
+	ByMonth:    {"TO_CHAR(DATE_TRUNC('month', o.created_at), 'YYYY-MM')", "TO_CHAR(DATE_TRUNC('month', o.created_at), 'FMMonth YYYY')"},
+	ByProvider: {"o.provider", "o.provider"},
+	ByStatus:   {"o.status", "o.status"},
+}
+
+func where(filter Filter) string {
+	var w []string
+	if filter.Status != "" {
+		w = append(w, "o.status = :status")
+	}
+	if !filter.From.IsZero() {
+		w = append(w, "o.created_at >= :from")
+	}
+	if !filter.To.IsZero() {
+		w = append(w, "o.created_at < :to")
+	}
+
+	if len(w) == 0 {
+		return ""
+	}
+
+	return `
+	WHERE
+		` + strings.Join(w, " AND\n\t\t")
+}
+
+func FetchRevenue(ctx context.Context, db sqlx.ExtContext, group Group, filter Filter) ([]Revenue, error) {
+	keys, ok := groupKeys[group]
+	if !ok {
+		return nil, fmt.Errorf("grouping revenue by %s is not supported", group)
+	}
+
+	q := `
+	SELECT
+		` + keys[0] + ` AS key,
+		` + keys[1] + ` AS label,
+		i.currency,
+		COUNT(DISTINCT o.order_id) AS orders,
+		COUNT(*) AS items,
+		SUM(i.net) AS net,
+		SUM(i.tax) AS tax,
+		SUM(i.amount) AS gross
+	FROM
+		orders AS o
+	INNER JOIN
+		order_items AS i ON i.order_id = o.order_id
+	INNER JOIN
+		courses AS c ON i.course_id = c.course_id` + where(filter) + `
+	GROUP BY
+		1, 2, 3
+	ORDER BY
+		1, 3`
+
+	rs := []Revenue{}
+	if err := database.NamedQuerySlice(ctx, db, q, filter, &rs); err != nil {
+		return nil, fmt.Errorf("selecting revenue by %s: %w", group, err)
+	}
+
+	return rs, nil
+}
+
+func FetchConversion(ctx context.Context, db sqlx.ExtContext, filter Filter) ([]Conversion, error) {
+	in := struct {
+		Filter
+		Free     string       `db:"free"`
+		Pending  order.Status `db:"pending"`
+		Success  order.Status `db:"success"`
+		Expired  order.Status `db:"expired"`
+		Refunded order.Status `db:"refunded"`
+	}{
+		Filter:   filter,
+		Free:     order.ProviderFree,
+		Pending:  order.Pending,
+		Success:  order.Success,
+		Expired:  order.Expired,
+		Refunded: order.Refunded,
+	}
+
+	w := where(filter)
+	if w == "" {
+		w = `
+	WHERE
+		o.provider <> :free`
+	} else {
+		w += ` AND
+		o.provider <> :free`
+	}
+
+	q := `
+	SELECT
+		o.provider,
+		COUNT(*) AS orders,
+		COUNT(*) FILTER (WHERE o.status = :pending) AS pending,
+		COUNT(*) FILTER (WHERE o.status IN (:success, :refunded)) AS succeeded,
+		COUNT(*) FILTER (WHERE o.status = :expired) AS expired,
+		COUNT(*) FILTER (WHERE o.status = :refunded) AS refunded
+	FROM
+		orders AS o` + w + `
+	GROUP BY
+		o.provider
+	ORDER BY
+		o.provider`
+
+	cs := []Conversion{}
+	if err := database.NamedQuerySlice(ctx, db, q, in, &cs); err != nil {
+		return nil, fmt.Errorf("selecting conversion: %w", err)
+	}
+
+	return cs, nil
+}
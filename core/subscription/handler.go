@@ -0,0 +1,73 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/api/web"
+	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/core/claims"
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/irsalhamdi/e-commerce-video/validate"
+	"github.com/jmoiron/sqlx"
+)
+
+func HandleCheckout(db *sqlx.DB, prov Provider) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		var sn SubscriptionNew
+		if err := web.Decode(w, r, &sn); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(sn); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		_, err = FetchActive(ctx, db, clm.UserID, time.Now().UTC())
+		if err == nil {
+			err := errors.New("user already has an active subscription")
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+		if !errors.Is(err, database.ErrDBNotFound) {
+			return fmt.Errorf("checking subscriptions of user[%s]: %w", clm.UserID, err)
+		}
+
+		_, resp, err := prov.Subscribe(ctx, clm.UserID, sn.Plan)
+		if err != nil {
+			if errors.Is(err, ErrPlanUnavailable) {
+				return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+			}
+			return fmt.Errorf("creating %s subscription checkout: %w", sn.Plan, err)
+		}
+
+		return web.Respond(ctx, w, resp, http.StatusOK)
+	}
+}
+
+func HandleShowCurrent(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		sub, err := FetchActive(ctx, db, clm.UserID, time.Now().UTC())
+		if err != nil {
+			err := fmt.Errorf("fetching subscription of user[%s]: %w", clm.UserID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		return web.Respond(ctx, w, sub, http.StatusOK)
+	}
+}
@@ -0,0 +1,102 @@
+package subscription
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/jmoiron/sqlx"
+)
+
+func Upsert(ctx context.Context, db sqlx.ExtContext, sub Subscription) error {
+	in := struct {
+		Subscription
+		Canceled Status `db:"canceled"`
+	}{
+		Subscription: sub,
+		Canceled:     Canceled,
+	}
+
+	const q = `
+	INSERT INTO subscriptions
+		(subscription_id, user_id, provider_id, plan, status, period_end, created_at, updated_at)
+	VALUES
+		(:subscription_id, :user_id, :provider_id, :plan, :status, :period_end, :created_at, :updated_at)
+	ON CONFLICT (provider_id) DO UPDATE
+	SET
+		plan = EXCLUDED.plan,
+		status = CASE WHEN subscriptions.status = :canceled THEN subscriptions.status ELSE EXCLUDED.status END,
+		period_end = GREATEST(subscriptions.period_end, EXCLUDED.period_end),
+		updated_at = EXCLUDED.updated_at`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("upserting subscription[%s]: %w", sub.ProviderID, err)
+	}
+
+	return nil
+}
+
+func UpdateStatus(ctx context.Context, db sqlx.ExtContext, sub Subscription) error {
+	in := struct {
+		Subscription
+		Canceled Status `db:"canceled"`
+	}{
+		Subscription: sub,
+		Canceled:     Canceled,
+	}
+
+	const q = `
+	UPDATE subscriptions
+	SET
+		status = CASE WHEN status = :canceled THEN status ELSE :status END,
+		period_end = GREATEST(period_end, :period_end),
+		updated_at = :updated_at
+	WHERE
+		provider_id = :provider_id
+	RETURNING subscription_id`
+
+	v := struct {
+		ID string `db:"subscription_id"`
+	}{}
+
+	if err := database.NamedQueryStruct(ctx, db, q, in, &v); err != nil {
+		return fmt.Errorf("updating status of subscription[%s]: %w", sub.ProviderID, err)
+	}
+
+	return nil
+}
+
+func FetchActive(ctx context.Context, db sqlx.ExtContext, userID string, now time.Time) (Subscription, error) {
+	in := struct {
+		UserID   string    `db:"user_id"`
+		Active   Status    `db:"active"`
+		Trialing Status    `db:"trialing"`
+		Now      time.Time `db:"now"`
+	}{
+		UserID:   userID,
+		Active:   Active,
+		Trialing: Trialing,
+		Now:      now,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		subscriptions
+	WHERE
+		user_id = :user_id AND
+		status IN (:active, :trialing) AND
+		period_end > :now
+	ORDER BY
+		period_end DESC
+	LIMIT 1`
+
+	var sub Subscription
+	if err := database.NamedQueryStruct(ctx, db, q, in, &sub); err != nil {
+		return Subscription{}, fmt.Errorf("selecting active subscription of user[%s]: %w", userID, err)
+	}
+
+	return sub, nil
+}
@@ -0,0 +1,44 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrPlanUnavailable = errors.New("subscription plan is not available")
+
+type Plan string
+
+const (
+	Monthly Plan = "monthly"
+	Yearly  Plan = "yearly"
+)
+
+type Status string
+
+const (
+	Active   Status = "active"
+	Trialing Status = "trialing"
+	PastDue  Status = "past_due"
+	Canceled Status = "canceled"
+)
+
+type Subscription struct {
+	ID         string    `json:"id" db:"subscription_id"`
+	UserID     string    `json:"userId" db:"user_id"`
+	ProviderID string    `json:"-" db:"provider_id"`
+	Plan       Plan      `json:"plan" db:"plan"`
+	Status     Status    `json:"status" db:"status"`
+	PeriodEnd  time.Time `json:"periodEnd" db:"period_end"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt  time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type SubscriptionNew struct {
+	Plan Plan `json:"plan" validate:"required,oneof=monthly yearly"`
+}
+
+type Provider interface {
+	Subscribe(ctx context.Context, userID string, plan Plan) (providerID string, resp any, err error)
+}
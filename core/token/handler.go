@@ -24,6 +24,9 @@ import (
 type Mailer interface {
 	SendActivationToken(token string, to string) error
 	SendRecoveryToken(token string, to string) error
+	SendLoginToken(token string, to string) error
+	SendGiftToken(token string, to string) error
+	SendCartReminder(to string, name string, items []string) error
 }
 
 func HandleToken(db *sqlx.DB, mailer Mailer, timeout time.Duration, bg *background.Background) web.Handler {
@@ -59,17 +62,24 @@ func HandleToken(db *sqlx.DB, mailer Mailer, timeout time.Duration, bg *backgrou
 		}
 
 		scope := in.Scope
+		ttl := 6 * time.Hour
 		switch scope {
 		case ActivationToken:
 			if usr.Active {
 				return weberr.BadRequest(fmt.Errorf("user %s is already active", usr.Email))
 			}
 		case RecoveryToken:
+		case LoginToken:
+			if !usr.Active {
+				err := fmt.Errorf("user %s is not active yet", usr.Email)
+				return weberr.NewError(err, err.Error(), http.StatusLocked)
+			}
+			ttl = 15 * time.Minute
 		default:
 			return weberr.BadRequest(fmt.Errorf("scope %s is not supported", scope))
 		}
 
-		text, token, err := GenToken(usr.ID, 6*time.Hour, scope)
+		text, token, err := GenToken(usr.ID, ttl, scope)
 		if err != nil {
 			return fmt.Errorf("generating random token: %w", err)
 		}
@@ -100,6 +110,10 @@ func HandleToken(db *sqlx.DB, mailer Mailer, timeout time.Duration, bg *backgrou
 				if err := mailer.SendRecoveryToken(text, usr.Email); err != nil {
 					return fmt.Errorf("failed to send recovery token %s to %s: %w", scope, usr.Email, err)
 				}
+			case LoginToken:
+				if err := mailer.SendLoginToken(text, usr.Email); err != nil {
+					return fmt.Errorf("failed to send login token %s to %s: %w", scope, usr.Email, err)
+				}
 			default:
 				return fmt.Errorf("scope %s is not supported", scope)
 			}
@@ -153,7 +167,7 @@ func HandleActivation(db *sqlx.DB, session *scs.SessionManager) web.Handler {
 			return err
 		}
 
-		if err := auth.SaveUserSession(ctx, session, usr.ID, usr.Role); err != nil {
+		if err := auth.SaveUserSession(ctx, db, session, r, usr.ID, usr.Role); err != nil {
 			return fmt.Errorf("store user[%s] in session: %w", usr.ID, err)
 		}
 
@@ -161,7 +175,56 @@ func HandleActivation(db *sqlx.DB, session *scs.SessionManager) web.Handler {
 	}
 }
 
-func HandleRecovery(db *sqlx.DB) web.Handler {
+// HandleLogin signs in the user owning the token sent by email. Users with
+// two-factor authentication turned on still have to verify their code.
+func HandleLogin(db *sqlx.DB, session *scs.SessionManager) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var in struct {
+			Token string `json:"token" validate:"required"`
+		}
+
+		if err := web.Decode(w, r, &in); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(in); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		hash := sha256.Sum256([]byte(in.Token))
+
+		usr, err := user.FetchByToken(ctx, db, hash[:], LoginToken)
+		if err != nil {
+			err := fmt.Errorf("fetching user by token: %w", err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.BadRequest(err)
+			}
+			return err
+		}
+
+		if err := DeleteByUser(ctx, db, usr.ID, LoginToken); err != nil {
+			return fmt.Errorf("deleting token by user[%s]: %w", usr.ID, err)
+		}
+
+		if !usr.Active {
+			err := fmt.Errorf("user %s is not active", usr.Email)
+			return weberr.NewError(err, err.Error(), http.StatusLocked)
+		}
+
+		pending, err := auth.Login(ctx, db, session, r, usr)
+		if err != nil {
+			return fmt.Errorf("store user[%s] in session: %w", usr.ID, err)
+		}
+
+		if pending {
+			return web.Respond(ctx, w, auth.LoginPending{TOTPRequired: true}, http.StatusAccepted)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+func HandleRecovery(db *sqlx.DB, session *scs.SessionManager) web.Handler {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 		var in struct {
 			Token           string `json:"token" validate:"required"`
@@ -204,6 +267,17 @@ func HandleRecovery(db *sqlx.DB) web.Handler {
 				return fmt.Errorf("recoverying user[%s]: %w", usr.ID, err)
 			}
 
+			// The password may have leaked: only the session used to change
+			// it survives, provided it belongs to the same user.
+			var keep string
+			if uid, ok := auth.SessionUser(ctx, session); ok && uid == usr.ID {
+				keep = auth.SessionID(ctx, session)
+			}
+
+			if err := auth.DeleteSessionsByUser(ctx, tx, usr.ID, keep); err != nil {
+				return fmt.Errorf("revoking sessions of user[%s]: %w", usr.ID, err)
+			}
+
 			return nil
 		})
 
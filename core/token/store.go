@@ -22,6 +22,38 @@ func Create(ctx context.Context, db sqlx.ExtContext, token Token) error {
 	return nil
 }
 
+func CreateGift(ctx context.Context, db sqlx.ExtContext, token Token) error {
+	const q = `
+	INSERT INTO tokens
+		(hash, order_id, expiry, scope)
+	VALUES
+		(:hash, :order_id, :expiry, :scope)`
+
+	if err := database.NamedExecContext(ctx, db, q, token); err != nil {
+		return fmt.Errorf("inserting gift token: %w", err)
+	}
+
+	return nil
+}
+
+func DeleteByHash(ctx context.Context, db sqlx.ExtContext, hash []byte) error {
+	data := struct {
+		Hash []byte `db:"hash"`
+	}{
+		Hash: hash,
+	}
+
+	const q = `
+	DELETE FROM tokens
+	WHERE hash = :hash`
+
+	if err := database.NamedExecContext(ctx, db, q, data); err != nil {
+		return fmt.Errorf("deleting token: %w", err)
+	}
+
+	return nil
+}
+
 func DeleteByUser(ctx context.Context, db sqlx.ExtContext, userID string, scope string) error {
 	data := struct {
 		UserID string `db:"user_id"`
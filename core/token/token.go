@@ -10,13 +10,16 @@ import (
 const (
 	ActivationToken = "activation"
 	RecoveryToken   = "recovery"
+	LoginToken      = "login"
+	GiftToken       = "gift"
 )
 
 type Token struct {
-	Hash   []byte    `json:"-" db:"hash"`
-	UserID string    `json:"userId" db:"user_id"`
-	Expiry time.Time `json:"expiry" db:"expiry"`
-	Scope  string    `json:"scope" db:"scope"`
+	Hash    []byte    `json:"-" db:"hash"`
+	UserID  string    `json:"userId" db:"user_id"`
+	OrderID *string   `json:"orderId,omitempty" db:"order_id"`
+	Expiry  time.Time `json:"expiry" db:"expiry"`
+	Scope   string    `json:"scope" db:"scope"`
 }
 
 func GenToken(userID string, ttl time.Duration, scope string) (string, Token, error) {
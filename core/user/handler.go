@@ -38,14 +38,15 @@ func HandleCreate(db *sqlx.DB) web.Handler {
 		now := time.Now().UTC()
 
 		usr := User{
-			ID:           validate.GenerateID(),
-			Name:         u.Name,
-			Email:        u.Email,
-			Role:         u.Role,
-			PasswordHash: hash,
-			CreatedAt:    now,
-			UpdatedAt:    now,
-			Active:       true,
+			ID:            validate.GenerateID(),
+			Name:          u.Name,
+			Email:         u.Email,
+			Role:          u.Role,
+			PasswordHash:  hash,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			Active:        true,
+			CartReminders: true,
 		}
 
 		if err := Create(ctx, db, usr); err != nil {
@@ -95,3 +96,30 @@ func HandleShowCurrent(db *sqlx.DB) web.Handler {
 		return web.Respond(ctx, w, user, http.StatusOK)
 	}
 }
+
+func HandleUpdatePreferences(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var up PreferencesUp
+		if err := web.Decode(w, r, &up); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		if up.CartReminders != nil {
+			if err := UpdatePreferences(ctx, db, clm.UserID, *up.CartReminders); err != nil {
+				return err
+			}
+		}
+
+		user, err := Fetch(ctx, db, clm.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching user[%s]: %w", clm.UserID, err)
+		}
+
+		return web.Respond(ctx, w, user, http.StatusOK)
+	}
+}
@@ -17,9 +17,9 @@ var (
 func Create(ctx context.Context, db sqlx.ExtContext, user User) error {
 	const q = `
 	INSERT INTO users
-		(user_id, name, email, password_hash, role, active, created_at, updated_at)
+		(user_id, name, email, password_hash, role, active, cart_reminders, created_at, updated_at)
 	VALUES
-	(:user_id, :name, :email, :password_hash, :role, :active, :created_at, :updated_at)`
+	(:user_id, :name, :email, :password_hash, :role, :active, :cart_reminders, :created_at, :updated_at)`
 
 	if err := database.NamedExecContext(ctx, db, q, user); err != nil {
 		if errors.Is(err, database.ErrDBDuplicatedEntry) {
@@ -39,6 +39,7 @@ func Update(ctx context.Context, db sqlx.ExtContext, user User) (User, error) {
 		email = :email,
 		role = :role,
 		active = :active,
+		cart_reminders = :cart_reminders,
 		password_hash = :password_hash,
 		updated_at = :updated_at,
 		version = version + 1
@@ -63,6 +64,97 @@ func Update(ctx context.Context, db sqlx.ExtContext, user User) (User, error) {
 	return user, nil
 }
 
+func UpdatePreferences(ctx context.Context, db sqlx.ExtContext, id string, cartReminders bool) error {
+	in := struct {
+		ID            string    `db:"user_id"`
+		CartReminders bool      `db:"cart_reminders"`
+		UpdatedAt     time.Time `db:"updated_at"`
+	}{
+		ID:            id,
+		CartReminders: cartReminders,
+		UpdatedAt:     time.Now().UTC(),
+	}
+
+	const q = `
+	UPDATE users
+	SET
+		cart_reminders = :cart_reminders,
+		updated_at = :updated_at,
+		version = version + 1
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("updating preferences of user[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+// UpdateTOTP stores the two-factor secret of the user. A nil secret turns
+// two-factor authentication off.
+func UpdateTOTP(ctx context.Context, db sqlx.ExtContext, id string, secret *string, enabled bool) error {
+	in := struct {
+		ID        string    `db:"user_id"`
+		Secret    *string   `db:"totp_secret"`
+		Enabled   bool      `db:"totp_enabled"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}{
+		ID:        id,
+		Secret:    secret,
+		Enabled:   enabled,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	const q = `
+	UPDATE users
+	SET
+		totp_secret = :totp_secret,
+		totp_enabled = :totp_enabled,
+		updated_at = :updated_at,
+		version = version + 1
+	WHERE
+		user_id = :user_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("updating two-factor secret of user[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
+// UpdateTOTPStep records the time step of the last accepted code. It returns
+// database.ErrDBNotFound when a code of the same or a later step was already
+// accepted, so that codes cannot be replayed.
+func UpdateTOTPStep(ctx context.Context, db sqlx.ExtContext, id string, step int64) error {
+	in := struct {
+		ID   string `db:"user_id"`
+		Step int64  `db:"totp_step"`
+	}{
+		ID:   id,
+		Step: step,
+	}
+
+	const q = `
+	UPDATE users
+	SET
+		totp_step = :totp_step
+	WHERE
+		user_id = :user_id AND
+		totp_step < :totp_step
+	RETURNING
+		user_id`
+
+	var out struct {
+		ID string `db:"user_id"`
+	}
+	if err := database.NamedQueryStruct(ctx, db, q, in, &out); err != nil {
+		return fmt.Errorf("updating two-factor step of user[%s]: %w", id, err)
+	}
+
+	return nil
+}
+
 func Fetch(ctx context.Context, db sqlx.ExtContext, id string) (User, error) {
 	in := struct {
 		ID string `db:"user_id"`
@@ -5,15 +5,19 @@ import (
 )
 
 type User struct {
-	ID           string    `json:"id" db:"user_id"`
-	Name         string    `json:"name" db:"name"`
-	Email        string    `json:"email" db:"email"`
-	Role         string    `json:"role" db:"role"`
-	Active       bool      `json:"active" db:"active"`
-	PasswordHash []byte    `json:"-" db:"password_hash"`
-	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
-	Version      int       `json:"-" db:"version"`
+	ID            string    `json:"id" db:"user_id"`
+	Name          string    `json:"name" db:"name"`
+	Email         string    `json:"email" db:"email"`
+	Role          string    `json:"role" db:"role"`
+	Active        bool      `json:"active" db:"active"`
+	CartReminders bool      `json:"cartReminders" db:"cart_reminders"`
+	TOTPEnabled   bool      `json:"totpEnabled" db:"totp_enabled"`
+	TOTPSecret    *string   `json:"-" db:"totp_secret"`
+	TOTPStep      int64     `json:"-" db:"totp_step"`
+	PasswordHash  []byte    `json:"-" db:"password_hash"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time `json:"updatedAt" db:"updated_at"`
+	Version       int       `json:"-" db:"version"`
 }
 
 type UserNew struct {
@@ -38,3 +42,7 @@ type UserUp struct {
 	Password        *string `json:"password"`
 	PasswordConfirm *string `json:"passwordConfirm" validate:"omitempty,eqfield=Password"`
 }
+
+type PreferencesUp struct {
+	CartReminders *bool `json:"cartReminders"`
+}
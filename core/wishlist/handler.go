@@ -0,0 +1,197 @@
+package wishlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/irsalhamdi/e-commerce-video/api/web"
+	"github.com/irsalhamdi/e-commerce-video/api/weberr"
+	"github.com/irsalhamdi/e-commerce-video/core/cart"
+	"github.com/irsalhamdi/e-commerce-video/core/claims"
+	"github.com/irsalhamdi/e-commerce-video/core/course"
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/irsalhamdi/e-commerce-video/validate"
+	"github.com/jmoiron/sqlx"
+)
+
+func HandleList(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		items, err := FetchItems(ctx, db, clm.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching user[%s] wishlist: %w", clm.UserID, err)
+		}
+
+		return web.Respond(ctx, w, items, http.StatusOK)
+	}
+}
+
+func HandleCreateItem(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var itnew ItemNew
+		if err := web.Decode(w, r, &itnew); err != nil {
+			return weberr.BadRequest(fmt.Errorf("unable to decode payload: %w", err))
+		}
+
+		if err := validate.Check(itnew); err != nil {
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		if _, err := course.Fetch(ctx, db, itnew.CourseID); err != nil {
+			err := fmt.Errorf("fetching course[%s]: %w", itnew.CourseID, err)
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		if err := checkOwned(ctx, db, clm.UserID, itnew.CourseID); err != nil {
+			return err
+		}
+
+		item := Item{
+			UserID:    clm.UserID,
+			CourseID:  itnew.CourseID,
+			CreatedAt: time.Now().UTC(),
+		}
+
+		if err := CreateItem(ctx, db, item); err != nil {
+			return fmt.Errorf("creating wishlist item[%s] for user[%s]: %w", item.CourseID, clm.UserID, err)
+		}
+
+		return web.Respond(ctx, w, item, http.StatusCreated)
+	}
+}
+
+func HandleDeleteItem(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		courseID := web.Param(r, "course_id")
+
+		if err := validate.CheckID(courseID); err != nil {
+			return weberr.BadRequest(fmt.Errorf("passed id is not valid: %w", err))
+		}
+
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		if err := DeleteItem(ctx, db, clm.UserID, courseID); err != nil {
+			return fmt.Errorf("deleting user[%s] wishlist item: %w", clm.UserID, err)
+		}
+
+		return web.Respond(ctx, w, nil, http.StatusNoContent)
+	}
+}
+
+// HandleMoveToCart puts a wishlisted course in the cart, removing it from
+// the wishlist.
+func HandleMoveToCart(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		courseID := web.Param(r, "course_id")
+
+		if err := validate.CheckID(courseID); err != nil {
+			return weberr.BadRequest(fmt.Errorf("passed id is not valid: %w", err))
+		}
+
+		clm, err := claims.Get(ctx)
+		if err != nil {
+			return weberr.NotAuthorized(errors.New("user not authenticated"))
+		}
+
+		if _, err := FetchItem(ctx, db, clm.UserID, courseID); err != nil {
+			if errors.Is(err, database.ErrDBNotFound) {
+				return weberr.NotFound(err)
+			}
+			return err
+		}
+
+		if err := checkOwned(ctx, db, clm.UserID, courseID); err != nil {
+			return err
+		}
+
+		c, err := course.Fetch(ctx, db, courseID)
+		if err != nil {
+			return fmt.Errorf("fetching course[%s]: %w", courseID, err)
+		}
+
+		now := time.Now().UTC()
+		item := cart.Item{
+			UserID:    clm.UserID,
+			CourseID:  c.ID,
+			Prices:    c.Prices,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+
+		err = database.Transaction(db, func(tx sqlx.ExtContext) error {
+			if _, err := cart.Upsert(ctx, tx, clm.UserID); err != nil {
+				return fmt.Errorf("upserting cart: %w", err)
+			}
+
+			if err := cart.CreateItem(ctx, tx, item); err != nil {
+				return fmt.Errorf("creating cart item: %w", err)
+			}
+
+			if err := DeleteItem(ctx, tx, clm.UserID, courseID); err != nil {
+				return fmt.Errorf("deleting wishlist item: %w", err)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("moving wishlist item[%s] of user[%s] to the cart: %w", courseID, clm.UserID, err)
+		}
+
+		return web.Respond(ctx, w, item, http.StatusCreated)
+	}
+}
+
+func HandleListPopular(db *sqlx.DB) web.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		limit := 10
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > 100 {
+				return weberr.BadRequest(fmt.Errorf("passed limit %s is not valid", v))
+			}
+			limit = n
+		}
+
+		ps, err := FetchPopular(ctx, db, limit)
+		if err != nil {
+			return fmt.Errorf("fetching most wishlisted courses: %w", err)
+		}
+
+		return web.Respond(ctx, w, ps, http.StatusOK)
+	}
+}
+
+func checkOwned(ctx context.Context, db sqlx.ExtContext, userID string, courseID string) error {
+	owned, err := course.FetchByOwner(ctx, db, userID)
+	if err != nil {
+		return fmt.Errorf("checking if course[%s] is already owned by user[%s]: %w", courseID, userID, err)
+	}
+
+	for _, o := range owned {
+		if courseID == o.ID {
+			err := errors.New("course already owned")
+			return weberr.NewError(err, err.Error(), http.StatusUnprocessableEntity)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package wishlist
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/irsalhamdi/e-commerce-video/database"
+	"github.com/jmoiron/sqlx"
+)
+
+func FetchItems(ctx context.Context, db sqlx.ExtContext, userID string) ([]Item, error) {
+	in := struct {
+		ID string `db:"user_id"`
+	}{
+		ID: userID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		wishlist_items
+	WHERE
+		user_id = :user_id
+	ORDER BY
+		created_at DESC`
+
+	items := []Item{}
+	if err := database.NamedQuerySlice(ctx, db, q, in, &items); err != nil {
+		return nil, fmt.Errorf("selecting wishlist items of user[%s]: %w", userID, err)
+	}
+
+	return items, nil
+}
+
+func FetchItem(ctx context.Context, db sqlx.ExtContext, userID string, courseID string) (Item, error) {
+	in := struct {
+		UserID   string `db:"user_id"`
+		CourseID string `db:"course_id"`
+	}{
+		UserID:   userID,
+		CourseID: courseID,
+	}
+
+	const q = `
+	SELECT
+		*
+	FROM
+		wishlist_items
+	WHERE
+		user_id = :user_id AND course_id = :course_id`
+
+	var item Item
+	if err := database.NamedQueryStruct(ctx, db, q, in, &item); err != nil {
+		return Item{}, fmt.Errorf("selecting wishlist item[%s] of user[%s]: %w", courseID, userID, err)
+	}
+
+	return item, nil
+}
+
+func CreateItem(ctx context.Context, db sqlx.ExtContext, item Item) error {
+	const q = `
+	INSERT INTO wishlist_items
+		(user_id, course_id, created_at)
+	VALUES
+		(:user_id, :course_id, :created_at)
+	ON CONFLICT (user_id, course_id) DO NOTHING`
+
+	if err := database.NamedExecContext(ctx, db, q, item); err != nil {
+		return fmt.Errorf("inserting wishlist item: %w", err)
+	}
+
+	return nil
+}
+
+func DeleteItem(ctx context.Context, db sqlx.ExtContext, userID string, courseID string) error {
+	in := struct {
+		UserID   string `db:"user_id"`
+		CourseID string `db:"course_id"`
+	}{
+		UserID:   userID,
+		CourseID: courseID,
+	}
+
+	const q = `
+	DELETE FROM
+		wishlist_items
+	WHERE
+		user_id = :user_id AND course_id = :course_id`
+
+	if err := database.NamedExecContext(ctx, db, q, in); err != nil {
+		return fmt.Errorf("deleting wishlist item: %w", err)
+	}
+
+	return nil
+}
+
+func FetchPopular(ctx context.Context, db sqlx.ExtContext, limit int) ([]Popular, error) {
+	in := struct {
+		Limit int `db:"limit"`
+	}{
+		Limit: limit,
+	}
+
+	const q = `
+	SELECT
+		c.course_id, c.name, COUNT(*) AS count
+	FROM
+		wishlist_items AS w
+	INNER JOIN
+		courses AS c ON c.course_id = w.course_id
+	GROUP BY
+		c.course_id, c.name
+	ORDER BY
+		count DESC, c.name
+	LIMIT :limit`
+
+	ps := []Popular{}
+	if err := database.NamedQuerySlice(ctx, db, q, in, &ps); err != nil {
+		return nil, fmt.Errorf("selecting most wishlisted courses: %w", err)
+	}
+
+	return ps, nil
+}
@@ -0,0 +1,22 @@
+package wishlist
+
+import (
+	"time"
+)
+
+type Item struct {
+	UserID    string    `json:"-" db:"user_id"`
+	CourseID  string    `json:"courseId" db:"course_id"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+type ItemNew struct {
+	CourseID string `json:"courseId" validate:"required,uuid"`
+}
+
+// Popular counts how many users wishlisted a course.
+type Popular struct {
+	CourseID string `json:"courseId" db:"course_id"`
+	Name     string `json:"name" db:"name"`
+	Count    int    `json:"count" db:"count"`
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SessionStore keeps the sessions of the scs manager in the sessions table,
+// so that they survive restarts and are shared between replicas.
+type SessionStore struct {
+	db *sqlx.DB
+}
+
+func NewSessionStore(db *sqlx.DB) *SessionStore {
+	return &SessionStore{db: db}
+}
+
+func (s *SessionStore) Find(token string) ([]byte, bool, error) {
+	return s.FindCtx(context.Background(), token)
+}
+
+func (s *SessionStore) Commit(token string, b []byte, expiry time.Time) error {
+	return s.CommitCtx(context.Background(), token, b, expiry)
+}
+
+func (s *SessionStore) Delete(token string) error {
+	return s.DeleteCtx(context.Background(), token)
+}
+
+func (s *SessionStore) FindCtx(ctx context.Context, token string) ([]byte, bool, error) {
+	in := struct {
+		Token string    `db:"token"`
+		Now   time.Time `db:"now"`
+	}{
+		Token: token,
+		Now:   time.Now().UTC(),
+	}
+
+	const q = `
+	SELECT
+		data
+	FROM
+		sessions
+	WHERE
+		token = :token AND
+		expiry > :now`
+
+	var out struct {
+		Data []byte `db:"data"`
+	}
+	if err := NamedQueryStruct(ctx, s.db, q, in, &out); err != nil {
+		if errors.Is(err, ErrDBNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("selecting session: %w", err)
+	}
+
+	return out.Data, true, nil
+}
+
+func (s *SessionStore) CommitCtx(ctx context.Context, token string, b []byte, expiry time.Time) error {
+	in := struct {
+		Token  string    `db:"token"`
+		Data   []byte    `db:"data"`
+		Expiry time.Time `db:"expiry"`
+	}{
+		Token:  token,
+		Data:   b,
+		Expiry: expiry.UTC(),
+	}
+
+	const q = `
+	INSERT INTO sessions
+		(token, data, expiry)
+	VALUES
+		(:token, :data, :expiry)
+	ON CONFLICT (token) DO UPDATE SET
+		data = EXCLUDED.data,
+		expiry = EXCLUDED.expiry`
+
+	if err := NamedExecContext(ctx, s.db, q, in); err != nil {
+		return fmt.Errorf("committing session: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SessionStore) DeleteCtx(ctx context.Context, token string) error {
+	in := struct {
+		Token string `db:"token"`
+	}{
+		Token: token,
+	}
+
+	const q = `
+	DELETE FROM
+		sessions
+	WHERE
+		token = :token`
+
+	if err := NamedExecContext(ctx, s.db, q, in); err != nil {
+		return fmt.Errorf("deleting session: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes the sessions expired before now.
+func (s *SessionStore) DeleteExpired(ctx context.Context) error {
+	in := struct {
+		Now time.Time `db:"now"`
+	}{
+		Now: time.Now().UTC(),
+	}
+
+	const q = `
+	DELETE FROM
+		sessions
+	WHERE
+		expiry <= :now`
+
+	if err := NamedExecContext(ctx, s.db, q, in); err != nil {
+		return fmt.Errorf("deleting expired sessions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,6 @@
+ALTER TABLE orders
+	ADD COLUMN IF NOT EXISTS provider   TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS payment_id TEXT NOT NULL DEFAULT '';
+
+UPDATE orders SET provider = 'stripe' WHERE provider_id LIKE 'cs\_%';
+UPDATE orders SET provider = 'paypal' WHERE provider = '';
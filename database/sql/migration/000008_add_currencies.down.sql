@@ -0,0 +1,7 @@
+ALTER TABLE order_items RENAME COLUMN amount TO price;
+UPDATE order_items SET price = price / 100;
+ALTER TABLE order_items DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS price INT NOT NULL DEFAULT 0;
+UPDATE courses SET price = COALESCE((prices->>'USD')::INT, 0) / 100;
+ALTER TABLE courses DROP COLUMN IF EXISTS prices;
@@ -0,0 +1,7 @@
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS prices JSONB NOT NULL DEFAULT '{}';
+UPDATE courses SET prices = jsonb_build_object('USD', price * 100);
+ALTER TABLE courses DROP COLUMN IF EXISTS price;
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'USD';
+ALTER TABLE order_items RENAME COLUMN price TO amount;
+UPDATE order_items SET amount = amount * 100;
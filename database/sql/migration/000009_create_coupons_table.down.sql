@@ -0,0 +1,3 @@
+ALTER TABLE orders DROP COLUMN IF EXISTS coupon_id;
+ALTER TABLE carts DROP COLUMN IF EXISTS coupon_id;
+DROP TABLE IF EXISTS coupons;
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS coupons
+(
+	coupon_id       UUID                        NOT NULL,
+	code            TEXT UNIQUE                 NOT NULL,
+	kind            TEXT                        NOT NULL,
+	percentage      INT                         NOT NULL DEFAULT 0,
+	amounts         JSONB                       NOT NULL DEFAULT '{}',
+	course_ids      TEXT[]                      NOT NULL DEFAULT '{}',
+	max_redemptions INT                         NOT NULL DEFAULT 0,
+	redemptions     INT                         NOT NULL DEFAULT 0,
+	expires_at      TIMESTAMP                   NOT NULL,
+	created_at      TIMESTAMP                   NOT NULL DEFAULT NOW(),
+	updated_at      TIMESTAMP                   NOT NULL DEFAULT NOW(),
+	version         INT                         NOT NULL DEFAULT 1,
+
+	CHECK (percentage BETWEEN 0 AND 100),
+	PRIMARY KEY (coupon_id)
+);
+
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS coupon_id UUID REFERENCES coupons(coupon_id) ON DELETE SET NULL;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS coupon_id UUID REFERENCES coupons(coupon_id) ON DELETE SET NULL;
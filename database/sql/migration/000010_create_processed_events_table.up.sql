@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS processed_events
+(
+	provider   TEXT      NOT NULL,
+	event_id   TEXT      NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+	PRIMARY KEY (provider, event_id)
+);
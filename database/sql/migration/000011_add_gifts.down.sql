@@ -0,0 +1,6 @@
+DELETE FROM tokens WHERE user_id IS NULL;
+ALTER TABLE tokens DROP COLUMN IF EXISTS order_id;
+ALTER TABLE tokens ALTER COLUMN user_id SET NOT NULL;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS recipient;
@@ -0,0 +1,6 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS recipient TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS owner_id UUID REFERENCES users(user_id) ON DELETE SET NULL;
+UPDATE orders SET owner_id = user_id;
+
+ALTER TABLE tokens ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS order_id UUID REFERENCES orders(order_id) ON DELETE CASCADE;
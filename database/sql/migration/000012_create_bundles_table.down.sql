@@ -0,0 +1,4 @@
+ALTER TABLE order_items DROP COLUMN IF EXISTS bundle_id;
+DROP TABLE IF EXISTS cart_bundles;
+DROP TABLE IF EXISTS bundle_courses;
+DROP TABLE IF EXISTS bundles;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS subscriptions
+(
+	subscription_id UUID                        NOT NULL,
+	user_id         UUID                        NOT NULL,
+	provider_id     TEXT UNIQUE                 NOT NULL,
+	plan            TEXT                        NOT NULL,
+	status          TEXT                        NOT NULL,
+	period_end      TIMESTAMP                   NOT NULL,
+	created_at      TIMESTAMP                   NOT NULL DEFAULT NOW(),
+	updated_at      TIMESTAMP                   NOT NULL DEFAULT NOW(),
+
+	PRIMARY KEY (subscription_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS invoice_counter;
+
+ALTER TABLE order_items DROP COLUMN IF EXISTS tax;
+ALTER TABLE order_items DROP COLUMN IF EXISTS net;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS invoice_number;
+ALTER TABLE orders DROP COLUMN IF EXISTS tax_rate;
+ALTER TABLE orders DROP COLUMN IF EXISTS country;
@@ -0,0 +1,32 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS country TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_rate INT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS invoice_number BIGINT UNIQUE;
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS net INT NOT NULL DEFAULT 0;
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax INT NOT NULL DEFAULT 0;
+UPDATE order_items SET net = amount;
+
+CREATE TABLE IF NOT EXISTS invoice_counter
+(
+	id            BOOLEAN                     NOT NULL DEFAULT TRUE,
+	last          BIGINT                      NOT NULL DEFAULT 0,
+
+	CHECK (id),
+	PRIMARY KEY (id)
+);
+
+UPDATE orders AS o
+SET
+	invoice_number = n.num
+FROM (
+	SELECT
+		order_id, ROW_NUMBER() OVER (ORDER BY updated_at, order_id) AS num
+	FROM
+		orders
+	WHERE
+		status IN ('success', 'refunded') AND provider <> 'free'
+) AS n
+WHERE
+	o.order_id = n.order_id;
+
+INSERT INTO invoice_counter (last) SELECT COALESCE(MAX(invoice_number), 0) FROM orders;
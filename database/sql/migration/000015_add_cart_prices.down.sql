@@ -0,0 +1,2 @@
+ALTER TABLE cart_bundles DROP COLUMN IF EXISTS prices;
+ALTER TABLE cart_items DROP COLUMN IF EXISTS prices;
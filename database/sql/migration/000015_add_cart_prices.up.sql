@@ -0,0 +1,5 @@
+ALTER TABLE cart_items ADD COLUMN IF NOT EXISTS prices JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE cart_bundles ADD COLUMN IF NOT EXISTS prices JSONB NOT NULL DEFAULT '{}';
+
+UPDATE cart_items AS ci SET prices = c.prices FROM courses AS c WHERE ci.course_id = c.course_id;
+UPDATE cart_bundles AS cb SET prices = b.prices FROM bundles AS b WHERE cb.bundle_id = b.bundle_id;
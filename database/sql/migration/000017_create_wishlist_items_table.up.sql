@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS wishlist_items
+(
+	user_id       UUID                        NOT NULL,
+	course_id     UUID                        NOT NULL,
+	created_at    TIMESTAMP                   NOT NULL DEFAULT NOW(),
+
+	PRIMARY KEY (user_id, course_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+	FOREIGN KEY (course_id) REFERENCES courses(course_id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS cart_reminders;
+ALTER TABLE carts DROP COLUMN IF EXISTS reminded_at;
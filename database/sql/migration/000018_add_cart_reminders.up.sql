@@ -0,0 +1,2 @@
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS reminded_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS cart_reminders BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS sessions
+(
+	token         TEXT                        NOT NULL,
+	data          BYTEA                       NOT NULL,
+	expiry        TIMESTAMP                   NOT NULL,
+
+	PRIMARY KEY (token)
+);
+
+CREATE INDEX IF NOT EXISTS sessions_expiry_idx ON sessions (expiry);
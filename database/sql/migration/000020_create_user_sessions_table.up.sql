@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS user_sessions
+(
+	session_id    UUID                        NOT NULL,
+	user_id       UUID                        NOT NULL,
+	device        TEXT                        NOT NULL,
+	user_agent    TEXT                        NOT NULL,
+	ip            TEXT                        NOT NULL,
+	created_at    TIMESTAMP                   NOT NULL,
+	last_seen_at  TIMESTAMP                   NOT NULL,
+	expires_at    TIMESTAMP                   NOT NULL,
+
+	PRIMARY KEY (session_id),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_sessions_user_id_idx ON user_sessions (user_id);
@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS totp_recovery_codes
+(
+	user_id       UUID                        NOT NULL,
+	code_hash     BYTEA                       NOT NULL,
+	created_at    TIMESTAMP                   NOT NULL,
+
+	PRIMARY KEY (user_id, code_hash),
+	FOREIGN KEY (user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
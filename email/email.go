@@ -22,6 +22,9 @@ type Emailer struct {
 type Links struct {
 	RecoveryURL   string
 	ActivationURL string
+	LoginURL      string
+	GiftURL       string
+	CartURL       string
 }
 
 func New(address string, password string, host string, port string, links Links) *Emailer {
@@ -80,3 +83,85 @@ func (e *Emailer) SendRecoveryToken(token string, to string) error {
 
 	return smtp.SendMail(e.host, e.auth, e.from, []string{to}, bytes)
 }
+
+func (e *Emailer) SendLoginToken(token string, to string) error {
+	t, err := template.New("email").ParseFS(templates, "templates/login.tmpl")
+	if err != nil {
+		return fmt.Errorf("parsing email template: %w", err)
+	}
+
+	var data struct {
+		Link string
+	}
+	data.Link = e.links.LoginURL + token
+
+	var body bytes.Buffer
+	err = t.ExecuteTemplate(&body, "html", data)
+	if err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+
+	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
+	subject := "Subject: Sign in to Govod\n"
+	src := fmt.Sprintf("From: %s\r\n", e.from)
+	dst := fmt.Sprintf("To: %s\r\n", to)
+	bytes := append([]byte(src+dst+subject+mime), body.Bytes()...)
+
+	return smtp.SendMail(e.host, e.auth, e.from, []string{to}, bytes)
+}
+
+func (e *Emailer) SendGiftToken(token string, to string) error {
+	t, err := template.New("email").ParseFS(templates, "templates/gift.tmpl")
+	if err != nil {
+		return fmt.Errorf("parsing email template: %w", err)
+	}
+
+	var data struct {
+		Link string
+	}
+	data.Link = e.links.GiftURL + token
+
+	var body bytes.Buffer
+	err = t.ExecuteTemplate(&body, "html", data)
+	if err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+
+	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
+	subject := "Subject: You received a course on Govod!\n"
+	src := fmt.Sprintf("From: %s\r\n", e.from)
+	dst := fmt.Sprintf("To: %s\r\n", to)
+	bytes := append([]byte(src+dst+subject+mime), body.Bytes()...)
+
+	return smtp.SendMail(e.host, e.auth, e.from, []string{to}, bytes)
+}
+
+func (e *Emailer) SendCartReminder(to string, name string, items []string) error {
+	t, err := template.New("email").ParseFS(templates, "templates/cart-reminder.tmpl")
+	if err != nil {
+		return fmt.Errorf("parsing email template: %w", err)
+	}
+
+	var data struct {
+		Name  string
+		Items []string
+		Link  string
+	}
+	data.Name = name
+	data.Items = items
+	data.Link = e.links.CartURL
+
+	var body bytes.Buffer
+	err = t.ExecuteTemplate(&body, "html", data)
+	if err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+
+	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
+	subject := "Subject: You left something in your cart\n"
+	src := fmt.Sprintf("From: %s\r\n", e.from)
+	dst := fmt.Sprintf("To: %s\r\n", to)
+	bytes := append([]byte(src+dst+subject+mime), body.Bytes()...)
+
+	return smtp.SendMail(e.host, e.auth, e.from, []string{to}, bytes)
+}
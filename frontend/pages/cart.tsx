@@ -5,12 +5,32 @@ import { useSession } from '@/session/context'
 import { fetcher } from '@/services/fetch'
 import Image from 'next/image'
 import { toast } from 'react-hot-toast'
-import { PayPalButtons, usePayPalScriptReducer } from '@paypal/react-paypal-js'
+import { DISPATCH_ACTION, PayPalButtons, usePayPalScriptReducer } from '@paypal/react-paypal-js'
+import { useEffect, useState } from 'react'
 import useSWR from 'swr'
 import { Cart, Course } from '@/services/types'
 
+const defaultCurrency = 'USD'
+
+// cartCurrencies returns the currencies every course in the cart is priced in.
+function cartCurrencies(cart?: Cart, courses?: Course[]): string[] {
+    if (!cart || !courses) {
+        return []
+    }
+
+    const prices = cart.items.map((item) => courses.find((c) => c.id === item.courseId)?.prices ?? {})
+    if (prices.length === 0) {
+        return []
+    }
+
+    return Object.keys(prices[0])
+        .filter((cur) => prices.every((p) => cur in p))
+        .sort()
+}
+
 type CartCourseProps = {
     course: string
+    currency: string
     onDelete: (x: string) => void
 }
 
@@ -30,7 +50,9 @@ function CartCourse(props: CartCourseProps) {
             </button>
             <Image className="mx-auto h-20 w-20" alt={course.name} src={course.imageUrl} width={80} height={32} />
             <div className="mx-auto ">{course.name}</div>
-            <div className="mx-auto font-bold">${course.price}</div>
+            <div className="mx-auto font-bold">
+                {((course.prices[props.currency] ?? 0) / 100).toFixed(2)} {props.currency}
+            </div>
         </div>
     )
 }
@@ -38,9 +60,24 @@ function CartCourse(props: CartCourseProps) {
 export default function CartPage() {
     const { isLoggedIn, isLoading } = useSession()
     const router = useRouter()
-    const [{ isPending, isResolved }] = usePayPalScriptReducer()
+    const [{ options, isPending, isResolved }, dispatch] = usePayPalScriptReducer()
+    const [selected, setSelected] = useState(defaultCurrency)
 
     const { data: cart, mutate } = useSWR<Cart>(isLoggedIn ? '/cart' : null)
+    const { data: courses } = useSWR<Course[]>(isLoggedIn ? '/courses' : null)
+
+    const currencies = cartCurrencies(cart, courses)
+    const currency = currencies.includes(selected) ? selected : currencies[0] ?? defaultCurrency
+
+    useEffect(() => {
+        if (options.currency === currency) {
+            return
+        }
+        dispatch({
+            type: DISPATCH_ACTION.RESET_OPTIONS,
+            value: { ...options, currency },
+        })
+    }, [currency, options, dispatch])
 
     if (isLoading) {
         return null
@@ -66,7 +103,10 @@ export default function CartPage() {
     const handleStripeCheckout = async (e: React.MouseEvent<HTMLButtonElement, MouseEvent>) => {
         e.preventDefault()
         try {
-            const res = await fetcher.fetch(`/orders/stripe`, { method: 'POST' })
+            const res = await fetcher.fetch(`/orders/stripe`, {
+                method: 'POST',
+                body: JSON.stringify({ currency }),
+            })
             const data = await res.json()
             window.location.href = data
         } catch (err) {
@@ -76,7 +116,10 @@ export default function CartPage() {
 
     const handlePaypalCheckout = async () => {
         try {
-            const res = await fetcher.fetch(`/orders/paypal`, { method: 'POST' })
+            const res = await fetcher.fetch(`/orders/paypal`, {
+                method: 'POST',
+                body: JSON.stringify({ currency }),
+            })
             const data = await res.json()
             return data.id
         } catch (err) {
@@ -110,6 +153,7 @@ export default function CartPage() {
                                     <CartCourse
                                         key={item.courseId}
                                         course={item.courseId}
+                                        currency={currency}
                                         onDelete={handleDeleteItem}
                                     />
                                 )
@@ -117,6 +161,24 @@ export default function CartPage() {
 
                         {cart?.items.length === 0 && <p className="p-5 text-center">Nothing in the cart..</p>}
 
+                        {currencies.length > 1 && (
+                            <div className="flex w-full flex-row items-center justify-end gap-2 p-2">
+                                <label htmlFor="currency">Currency</label>
+                                <select
+                                    id="currency"
+                                    className="rounded border p-1"
+                                    value={currency}
+                                    onChange={(e) => setSelected(e.target.value)}
+                                >
+                                    {currencies.map((cur) => (
+                                        <option key={cur} value={cur}>
+                                            {cur}
+                                        </option>
+                                    ))}
+                                </select>
+                            </div>
+                        )}
+
                         <div className="flex w-full flex-col items-center gap-1 p-2">
                             <button
                                 onClick={handleStripeCheckout}
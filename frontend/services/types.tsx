@@ -3,7 +3,7 @@ export type Course = {
     name: string
     description: string
     imageUrl: string
-    price: number
+    prices: Record<string, number>
 }
 
 export type Video = {
@@ -0,0 +1,90 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Prices maps an ISO 4217 currency code to an amount expressed in the
+// minor unit of that currency (e.g. cents for USD).
+type Prices map[string]int
+
+func (p Prices) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(p)
+}
+
+func (p *Prices) Scan(src any) error {
+	var b []byte
+	switch v := src.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	case nil:
+		*p = Prices{}
+		return nil
+	default:
+		return errors.New("prices must be scanned from a json value")
+	}
+
+	prices := Prices{}
+	if err := json.Unmarshal(b, &prices); err != nil {
+		return err
+	}
+
+	*p = prices
+	return nil
+}
+
+// Equal reports whether both maps hold the same amounts for the same currencies.
+func (p Prices) Equal(o Prices) bool {
+	if len(p) != len(o) {
+		return false
+	}
+	for cur, amount := range p {
+		if v, ok := o[cur]; !ok || v != amount {
+			return false
+		}
+	}
+	return true
+}
+
+var zeroDecimal = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "ISK": true,
+	"JPY": true, "KMF": true, "KRW": true, "PYG": true, "RWF": true,
+	"UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true,
+	"XPF": true,
+}
+
+func Exponent(currency string) int {
+	if zeroDecimal[strings.ToUpper(currency)] {
+		return 0
+	}
+	return 2
+}
+
+func Format(amount int, currency string) string {
+	exp := Exponent(currency)
+	if exp == 0 {
+		return strconv.Itoa(amount)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	s := strconv.Itoa(amount)
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
@@ -0,0 +1,70 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		amount   int
+		currency string
+		exp      string
+	}{
+		{amount: 0, currency: "USD", exp: "0.00"},
+		{amount: 5, currency: "USD", exp: "0.05"},
+		{amount: 50, currency: "EUR", exp: "0.50"},
+		{amount: 1050, currency: "EUR", exp: "10.50"},
+		{amount: 123456, currency: "usd", exp: "1234.56"},
+		{amount: -250, currency: "USD", exp: "-2.50"},
+		{amount: 1500, currency: "JPY", exp: "1500"},
+	}
+
+	for _, tt := range tests {
+		if got := Format(tt.amount, tt.currency); got != tt.exp {
+			t.Errorf("format %d %s: expected %s, got %s", tt.amount, tt.currency, tt.exp, got)
+		}
+	}
+}
+
+func TestPricesScan(t *testing.T) {
+	exp := Prices{"USD": 1000, "EUR": 900}
+
+	v, err := exp.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got Prices
+	if err := got.Scan(v); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(got, exp); diff != "" {
+		t.Fatalf("wrong scanned prices. Diff: \n%s", diff)
+	}
+
+	if err := got.Scan(nil); err != nil || len(got) != 0 {
+		t.Fatalf("scanning a null value should result in empty prices: %v", got)
+	}
+}
+
+func TestPricesEqual(t *testing.T) {
+	tests := []struct {
+		a, b Prices
+		exp  bool
+	}{
+		{a: Prices{}, b: nil, exp: true},
+		{a: Prices{"USD": 1000}, b: Prices{"USD": 1000}, exp: true},
+		{a: Prices{"USD": 1000}, b: Prices{"USD": 900}, exp: false},
+		{a: Prices{"USD": 1000}, b: Prices{"USD": 1000, "EUR": 900}, exp: false},
+		{a: Prices{"USD": 0}, b: Prices{"EUR": 0}, exp: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.a.Equal(tt.b); got != tt.exp {
+			t.Errorf("comparing %v with %v: expected %t, got %t", tt.a, tt.b, tt.exp, got)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package tax
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rates maps an ISO 3166-1 alpha-2 country code to the tax rate applied to
+// buyers billed in that country, expressed in basis points (2200 is 22%).
+type Rates map[string]int
+
+func (r Rates) Rate(country string) int {
+	return r[strings.ToUpper(country)]
+}
+
+// MakeRates validates the configured rates, keying them by upper case
+// country codes as Rate expects.
+func MakeRates(m map[string]int) (Rates, error) {
+	r := make(Rates, len(m))
+	for c, v := range m {
+		if len(c) != 2 {
+			return nil, fmt.Errorf("country code %q is not valid", c)
+		}
+		if v < 0 || v > 10000 {
+			return nil, fmt.Errorf("tax rate %d of %s is out of range", v, c)
+		}
+
+		c = strings.ToUpper(c)
+		if _, ok := r[c]; ok {
+			return nil, fmt.Errorf("country code %s is set more than once", c)
+		}
+		r[c] = v
+	}
+	return r, nil
+}
+
+// Split breaks a tax inclusive amount into its net and tax parts, rounding
+// the net amount to the nearest minor unit.
+func Split(gross int, rate int) (net int, tax int) {
+	net = (gross*10000 + (10000+rate)/2) / (10000 + rate)
+	return net, gross - net
+}
+
+func Format(rate int) string {
+	return fmt.Sprintf("%d.%02d%%", rate/100, rate%100)
+}
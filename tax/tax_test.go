@@ -0,0 +1,65 @@
+package tax
+
+import (
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		gross int
+		rate  int
+		net   int
+		tax   int
+	}{
+		{gross: 0, rate: 2200, net: 0, tax: 0},
+		{gross: 1000, rate: 0, net: 1000, tax: 0},
+		{gross: 1220, rate: 2200, net: 1000, tax: 220},
+		{gross: 999, rate: 2200, net: 819, tax: 180},
+		{gross: 1, rate: 1900, net: 1, tax: 0},
+		{gross: 12345, rate: 550, net: 11701, tax: 644},
+	}
+
+	for _, tt := range tests {
+		net, tax := Split(tt.gross, tt.rate)
+		if net != tt.net || tax != tt.tax {
+			t.Errorf("split %d at %d: expected %d+%d, got %d+%d", tt.gross, tt.rate, tt.net, tt.tax, net, tax)
+		}
+	}
+}
+
+func TestRates(t *testing.T) {
+	r := Rates{"IT": 2200, "DE": 1900}
+
+	if got := r.Rate("it"); got != 2200 {
+		t.Errorf("expected rate 2200 for it, got %d", got)
+	}
+
+	if got := r.Rate("US"); got != 0 {
+		t.Errorf("expected no rate for US, got %d", got)
+	}
+
+	r, err := MakeRates(map[string]int{"it": 2200, "DE": 1900})
+	if err != nil {
+		t.Errorf("expected valid rates: %v", err)
+	}
+
+	if got := r.Rate("IT"); got != 2200 {
+		t.Errorf("expected lower case country to be normalised, got rate %d for IT", got)
+	}
+
+	if _, err := MakeRates(map[string]int{"ITA": 2200}); err == nil {
+		t.Error("expected invalid country to be rejected")
+	}
+
+	if _, err := MakeRates(map[string]int{"IT": 10001}); err == nil {
+		t.Error("expected invalid rate to be rejected")
+	}
+
+	if _, err := MakeRates(map[string]int{"IT": 2200, "it": 1000}); err == nil {
+		t.Error("expected duplicated country to be rejected")
+	}
+
+	if got := Format(550); got != "5.50%" {
+		t.Errorf("expected 5.50%%, got %s", got)
+	}
+}
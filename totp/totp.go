@@ -0,0 +1,108 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The parameters of RFC 6238 understood by every authenticator app.
+const (
+	digits = 6
+	period = 30
+	skew   = 1
+)
+
+var ErrInvalidCode = errors.New("invalid code")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a random base32 encoded secret.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// URI returns the provisioning uri of the secret, usually shown as a QR code
+// to be scanned by the authenticator app.
+func URI(issuer string, account string, secret string) string {
+	q := make(url.Values)
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(digits))
+	q.Set("period", fmt.Sprint(period))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+
+	return u.String()
+}
+
+// Step returns the time step containing t.
+func Step(t time.Time) int64 {
+	return t.Unix() / period
+}
+
+// Code returns the code of the secret for the passed time step.
+func Code(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("decoding secret: %w", err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, bin%mod), nil
+}
+
+// Validate checks the code against the secret at time t, tolerating one
+// step of clock drift, and returns the step it belongs to. Callers should
+// reject steps not later than the last accepted one, so that a code cannot
+// be used twice.
+func Validate(secret string, code string, t time.Time) (int64, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != digits {
+		return 0, ErrInvalidCode
+	}
+
+	now := Step(t)
+	for step := now - skew; step <= now+skew; step++ {
+		exp, err := Code(secret, step)
+		if err != nil {
+			return 0, err
+		}
+		if hmac.Equal([]byte(exp), []byte(code)) {
+			return step, nil
+		}
+	}
+
+	return 0, ErrInvalidCode
+}
@@ -0,0 +1,82 @@
+package totp
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA1 test vectors of RFC 6238, truncated to 6 digits.
+var secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		exp  string
+	}{
+		{unix: 59, exp: "287082"},
+		{unix: 1111111109, exp: "081804"},
+		{unix: 1111111111, exp: "050471"},
+		{unix: 1234567890, exp: "005924"},
+		{unix: 2000000000, exp: "279037"},
+		{unix: 20000000000, exp: "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.exp {
+			t.Errorf("code at %d: expected %s, got %s", tt.unix, tt.exp, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	for _, d := range []time.Duration{-30 * time.Second, 0, 30 * time.Second} {
+		code, err := Code(secret, Step(now.Add(d)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		step, err := Validate(secret, code, now)
+		if err != nil {
+			t.Fatalf("code with drift %s should be valid: %v", d, err)
+		}
+		if step != Step(now.Add(d)) {
+			t.Fatalf("code with drift %s: expected step %d, got %d", d, Step(now.Add(d)), step)
+		}
+	}
+
+	old, err := Code(secret, Step(now.Add(-time.Minute)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, code := range []string{old, "", "12345", "abcdef"} {
+		if _, err := Validate(secret, code, now); !errors.Is(err, ErrInvalidCode) {
+			t.Errorf("code %q should be invalid, got %v", code, err)
+		}
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	s, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Code(s, 1); err != nil {
+		t.Fatalf("generated secret cannot be used: %v", err)
+	}
+
+	uri := URI("Govod", "admin@govod.com", s)
+	if !strings.HasPrefix(uri, "otpauth://totp/Govod:admin@govod.com?") || !strings.Contains(uri, "secret="+s) {
+		t.Fatalf("wrong provisioning uri: %s", uri)
+	}
+}